@@ -1,17 +1,16 @@
 #version 330 core
 layout (location = 0) in vec4 vertex; // <vec2 position, vec2 texCoords>
+layout (location = 1) in vec3 instance; // <vec2 offset, float size>, per particle
+layout (location = 2) in vec4 color; // per particle
 
 out vec2 TexCoords;
 out vec4 ParticleColor;
 
 uniform mat4 projection;
-uniform vec2 offset;
-uniform vec4 color;
 
 void main()
 {
-    float scale = 10.0f;
     TexCoords = vertex.zw;
     ParticleColor = color;
-    gl_Position = projection * vec4((vertex.xy * scale) + offset, 0.0, 1.0);
-}
\ No newline at end of file
+    gl_Position = projection * vec4((vertex.xy - 0.5) * instance.z + instance.xy, 0.0, 1.0);
+}
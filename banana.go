@@ -4,29 +4,27 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/jakecoffman/cp/v2"
 	"github.com/jakecoffman/fam/eng"
-	"math/rand"
 )
 
 type Banana struct {
-	Game    *Game
-	Texture *eng.Texture2D
+	// Fruit names the texture drawn for this banana.
+	Fruit string
 
 	*eng.Object
 }
 
-func NewBanana(g *Game, pos cp.Vector, radius float64) *Banana {
-	texture := g.Texture("banana")
-	v := rand.Intn(10)
+func NewBanana(w *World, pos cp.Vector, radius float64) *Banana {
+	fruit := "banana"
+	v := w.Rand.Intn(10)
 	if v < 1 {
-		texture = g.Texture("strawberry")
+		fruit = "strawberry"
 	} else if v < 4 {
-		texture = g.Texture("blueberry")
+		fruit = "blueberry"
 	}
 
 	p := &Banana{
-		Game:    g,
-		Object:  &eng.Object{},
-		Texture: texture,
+		Object: &eng.Object{},
+		Fruit:  fruit,
 	}
 	const bananaMass = 10
 	p.Body = cp.NewBody(bananaMass, cp.MomentForCircle(bananaMass, radius, radius, cp.Vector{0, 0}))
@@ -40,21 +38,21 @@ func NewBanana(g *Game, pos cp.Vector, radius float64) *Banana {
 
 	p.Shape.UserData = p
 	p.Body.SetPosition(pos)
-	g.Space.AddBody(p.Body)
-	g.Space.AddShape(p.Shape)
+	w.Space.AddBody(p.Body)
+	w.Space.AddShape(p.Shape)
 	return p
 }
 
-func (p *Banana) Update(g *Game, dt float64) {
-	p.Object.Update(g.Space, dt, worldWidth, worldHeight)
+func (p *Banana) Update(w *World, dt float64) {
+	p.Object.Update(w.Space, dt, w.Width, w.Height)
 }
 
-func (p *Banana) Draw(renderer *eng.SpriteRenderer, alpha float64) {
-	renderer.DrawSprite(p.Texture, p.SmoothPos(alpha), p.Size(), p.Angle(), mgl32.Vec3{1, 1, 1})
+func (p *Banana) Draw(g *Game, alpha float64) {
+	g.drawSprite(p.Fruit, p.SmoothPos(alpha), p.Size(), p.Angle(), mgl32.Vec3{1, 1, 1})
 }
 
 func BananaPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
-	game := data.(*Game)
+	world := data.(*World)
 
 	a, b := arb.Shapes()
 	banana := a.UserData.(*Banana)
@@ -66,20 +64,23 @@ func BananaPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
 		// max size reached
 		if player.Circle.Radius() < playerRadius*5 {
 			player.Circle.SetRadius(player.Circle.Radius() * 1.1)
+			player.Stats.grow(player.Circle.Radius())
 		}
 
 		space.AddPostStepCallback(func(s *cp.Space, a interface{}, b interface{}) {
 			if banana.Shape == nil {
 				return
 			}
+			world.emit(Event{Kind: EventEat, Pos: banana.Position(), Player: player})
+			player.Stats.Fruit[banana.Fruit]++
 			banana.Shape.UserData = nil
 			s.RemoveShape(banana.Shape)
 			s.RemoveBody(banana.Body)
 			banana.Shape = nil
 			banana.Body = nil
-			for i := 0; i < len(game.Bananas); i++ {
-				if game.Bananas[i] == banana {
-					game.Bananas = append(game.Bananas[:i], game.Bananas[i+1:]...)
+			for i := 0; i < len(world.Bananas); i++ {
+				if world.Bananas[i] == banana {
+					world.Bananas = append(world.Bananas[:i], world.Bananas[i+1:]...)
 					return
 				}
 			}
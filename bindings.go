@@ -0,0 +1,386 @@
+package fam
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+const bindingsFile = "bindings.json"
+
+// axes closer to rest than this read as zero
+const axisDeadzone = 0.15
+
+// InputAction is a named thing a key, gamepad button or axis can be bound to.
+type InputAction string
+
+const (
+	InputMoveLeft    InputAction = "MoveLeft"
+	InputMoveRight   InputAction = "MoveRight"
+	InputJump        InputAction = "Jump"
+	InputGrab        InputAction = "Grab"
+	InputSpawnBanana InputAction = "SpawnBanana"
+	InputSpawnBomb   InputAction = "SpawnBomb"
+	InputSpawnCrate  InputAction = "SpawnCrate"
+	InputSpawnBumper InputAction = "SpawnBumper"
+	InputSpawnSpring InputAction = "SpawnSpring"
+	InputFullscreen  InputAction = "Fullscreen"
+	InputAddPlayer   InputAction = "AddPlayer"
+)
+
+// InputActions lists every action in the order the controls screen shows them.
+var InputActions = []InputAction{
+	InputMoveLeft,
+	InputMoveRight,
+	InputJump,
+	InputGrab,
+	InputSpawnBanana,
+	InputSpawnBomb,
+	InputSpawnCrate,
+	InputSpawnBumper,
+	InputSpawnSpring,
+	InputFullscreen,
+	InputAddPlayer,
+}
+
+var inputActionNames = map[InputAction]string{
+	InputMoveLeft:    "Move left",
+	InputMoveRight:   "Move right",
+	InputJump:        "Jump",
+	InputGrab:        "Grab rope",
+	InputSpawnBanana: "Spawn banana",
+	InputSpawnBomb:   "Spawn bomb",
+	InputSpawnCrate:  "Spawn crate",
+	InputSpawnBumper: "Place bumper",
+	InputSpawnSpring: "Place spring pad",
+	InputFullscreen:  "Fullscreen",
+	InputAddPlayer:   "Add player",
+}
+
+func (a InputAction) String() string {
+	if name, ok := inputActionNames[a]; ok {
+		return name
+	}
+	return string(a)
+}
+
+type BindingKind int
+
+const (
+	BindKey BindingKind = iota
+	BindButton
+	BindAxis
+)
+
+var bindingKindNames = []string{"key", "button", "axis"}
+
+func (k BindingKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(bindingKindNames) {
+		return nil, fmt.Errorf("unknown binding kind %d", int(k))
+	}
+	return []byte(bindingKindNames[k]), nil
+}
+
+func (k *BindingKind) UnmarshalText(text []byte) error {
+	for i, name := range bindingKindNames {
+		if name == string(text) {
+			*k = BindingKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown binding kind %q", text)
+}
+
+// Binding is one key, gamepad button or gamepad axis direction.
+type Binding struct {
+	Kind BindingKind
+	// Code is the Key, GamepadButton or GamepadAxis.
+	Code int
+	// Sign is the direction an axis has to be pushed, 1 or -1.
+	Sign float64 `json:",omitempty"`
+}
+
+func KeyBinding(key Key) Binding {
+	return Binding{Kind: BindKey, Code: int(key)}
+}
+
+func ButtonBinding(button GamepadButton) Binding {
+	return Binding{Kind: BindButton, Code: int(button)}
+}
+
+func AxisBinding(axis GamepadAxis, sign float64) Binding {
+	return Binding{Kind: BindAxis, Code: int(axis), Sign: sign}
+}
+
+func (b Binding) String() string {
+	switch b.Kind {
+	case BindButton:
+		return GamepadButton(b.Code).String()
+	case BindAxis:
+		if b.Sign < 0 {
+			return fmt.Sprintf("%v -", GamepadAxis(b.Code))
+		}
+		return fmt.Sprintf("%v +", GamepadAxis(b.Code))
+	}
+	return keyName(Key(b.Code))
+}
+
+// value reads how far the binding is pressed, from 0 to 1. Keyboard players
+// have no pad and only see keys; gamepad players only see their pad.
+func (b Binding) value(keys map[Key]bool, pad *GamepadState) float64 {
+	switch b.Kind {
+	case BindKey:
+		if pad == nil && keys[Key(b.Code)] {
+			return 1
+		}
+	case BindButton:
+		if pad != nil && b.Code >= 0 && b.Code < len(pad.Buttons) && pad.Buttons[b.Code] {
+			return 1
+		}
+	case BindAxis:
+		if pad == nil || b.Code < 0 || b.Code >= len(pad.Axes) {
+			return 0
+		}
+		raw := pad.Axes[b.Code] * b.Sign
+		if raw < axisDeadzone {
+			return 0
+		}
+		// Rescale the range [deadzone, 1] → [0, 1] so the full output
+		// range is available after dead-zone removal.
+		return math.Min((raw-axisDeadzone)/(1-axisDeadzone), 1)
+	}
+	return 0
+}
+
+// Bindings maps each action to everything that triggers it.
+type Bindings map[InputAction][]Binding
+
+func DefaultBindings() Bindings {
+	return Bindings{
+		InputMoveLeft:    {AxisBinding(AxisLeftX, -1), ButtonBinding(ButtonDpadLeft)},
+		InputMoveRight:   {AxisBinding(AxisLeftX, 1), ButtonBinding(ButtonDpadRight)},
+		InputJump:        {ButtonBinding(ButtonA)},
+		InputGrab:        {ButtonBinding(ButtonX)},
+		InputSpawnBanana: {KeyBinding(KeyE)},
+		InputSpawnBomb:   {KeyBinding(KeyQ)},
+		InputSpawnCrate:  {KeyBinding(KeyC)},
+		InputSpawnBumper: {KeyBinding(KeyX)},
+		InputSpawnSpring: {KeyBinding(KeyV)},
+		InputFullscreen:  {KeyBinding(KeyF)},
+		InputAddPlayer:   {KeyBinding(KeyEnter)},
+	}
+}
+
+// KeySet is the keys one keyboard player moves, jumps and grabs with.
+type KeySet struct {
+	Name     string
+	Bindings Bindings
+}
+
+func newKeySet(name string, left, right, grab Key, jump ...Key) KeySet {
+	set := KeySet{
+		Name: name,
+		Bindings: Bindings{
+			InputMoveLeft:  {KeyBinding(left)},
+			InputMoveRight: {KeyBinding(right)},
+			InputGrab:      {KeyBinding(grab)},
+		},
+	}
+	for _, key := range jump {
+		set.Bindings.Add(InputJump, KeyBinding(key))
+	}
+	return set
+}
+
+func DefaultKeySets() []KeySet {
+	return []KeySet{
+		newKeySet("WASD", KeyA, KeyD, KeyS, KeySpace, KeyW),
+		newKeySet("Arrows", KeyLeft, KeyRight, KeyDown, KeyRightShift, KeyUp),
+		newKeySet("IJKL", KeyJ, KeyL, KeyK, KeySemicolon, KeyI),
+		newKeySet("Numpad", KeyKP4, KeyKP6, KeyKP5, KeyKP0, KeyKP8),
+	}
+}
+
+// HasKey reports whether key moves, jumps or grabs for this key set.
+func (k KeySet) HasKey(key Key) bool {
+	for action := range k.Bindings {
+		if k.Bindings.HasKey(action, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Controls are the bindings saved in the bindings file.
+type Controls struct {
+	// Bindings drive gamepad players and the game-wide actions.
+	Bindings Bindings
+	// KeySets are handed out in order to keyboard players as they join.
+	KeySets []KeySet
+}
+
+// gameAction returns the game-wide action key is bound to, if any. A key set
+// using the same key takes it over once its player joins.
+func (c Controls) gameAction(key Key) (InputAction, bool) {
+	for _, action := range InputActions {
+		if !playerAction(action) && c.Bindings.HasKey(action, key) {
+			return action, true
+		}
+	}
+	return "", false
+}
+
+// keySetWith returns the first key set that uses key.
+func (c Controls) keySetWith(key Key) (KeySet, bool) {
+	for _, set := range c.KeySets {
+		if set.HasKey(key) {
+			return set, true
+		}
+	}
+	return KeySet{}, false
+}
+
+// KeyClashes lists the keys bound both to a game-wide action and in a key
+// set, which stop working for the game once that key set's player joins.
+func (c Controls) KeyClashes() []Key {
+	var clashes []Key
+	for _, action := range InputActions {
+		if playerAction(action) {
+			continue
+		}
+		for _, bind := range c.Bindings[action] {
+			if bind.Kind != BindKey {
+				continue
+			}
+			if _, ok := c.keySetWith(Key(bind.Code)); ok {
+				clashes = append(clashes, Key(bind.Code))
+			}
+		}
+	}
+	return clashes
+}
+
+// playerAction reports whether action moves a player, rather than acting on
+// the whole game.
+func playerAction(action InputAction) bool {
+	return action == InputMoveLeft || action == InputMoveRight || action == InputJump || action == InputGrab
+}
+
+func DefaultControls() Controls {
+	return Controls{
+		Bindings: DefaultBindings(),
+		KeySets:  DefaultKeySets(),
+	}
+}
+
+// LoadControls reads a bindings file. Actions the file doesn't mention keep
+// their default bindings.
+func LoadControls(filename string) (Controls, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Controls{}, err
+	}
+	var loaded Controls
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		return Controls{}, fmt.Errorf("%v: %v", filename, err)
+	}
+	c := DefaultControls()
+	for action, binds := range loaded.Bindings {
+		c.Bindings[action] = binds
+	}
+	if len(loaded.KeySets) > 0 {
+		c.KeySets = loaded.KeySets
+	}
+	for i := range c.KeySets {
+		if c.KeySets[i].Bindings == nil {
+			c.KeySets[i].Bindings = Bindings{}
+		}
+	}
+	return c, nil
+}
+
+func SaveControls(filename string, c Controls) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Value is how far action is pressed, from 0 to 1, on the keyboard when pad
+// is nil and on the gamepad otherwise.
+func (b Bindings) Value(action InputAction, keys map[Key]bool, pad *GamepadState) float64 {
+	var v float64
+	for _, bind := range b[action] {
+		v = math.Max(v, bind.value(keys, pad))
+	}
+	return v
+}
+
+func (b Bindings) Pressed(action InputAction, keys map[Key]bool, pad *GamepadState) bool {
+	return b.Value(action, keys, pad) > 0
+}
+
+// HasKey reports whether key is bound to action.
+func (b Bindings) HasKey(action InputAction, key Key) bool {
+	for _, bind := range b[action] {
+		if bind.Kind == BindKey && Key(bind.Code) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Add binds bind to action unless it already is.
+func (b Bindings) Add(action InputAction, bind Binding) {
+	for _, existing := range b[action] {
+		if existing == bind {
+			return
+		}
+	}
+	b[action] = append(b[action], bind)
+}
+
+func (b Bindings) Remove(action InputAction, i int) {
+	b[action] = append(b[action][:i:i], b[action][i+1:]...)
+}
+
+var keyNames = map[Key]string{
+	KeySpace:        "Space",
+	KeyEscape:       "Escape",
+	KeyEnter:        "Enter",
+	KeyTab:          "Tab",
+	KeyBackspace:    "Backspace",
+	KeyInsert:       "Insert",
+	KeyDelete:       "Delete",
+	KeyRight:        "Right",
+	KeyLeft:         "Left",
+	KeyDown:         "Down",
+	KeyUp:           "Up",
+	KeyPageUp:       "Page Up",
+	KeyPageDown:     "Page Down",
+	KeyHome:         "Home",
+	KeyEnd:          "End",
+	KeyKPEnter:      "Keypad Enter",
+	KeyLeftShift:    "Left Shift",
+	KeyLeftControl:  "Left Ctrl",
+	KeyLeftAlt:      "Left Alt",
+	KeyRightShift:   "Right Shift",
+	KeyRightControl: "Right Ctrl",
+	KeyRightAlt:     "Right Alt",
+}
+
+func keyName(key Key) string {
+	if name, ok := keyNames[key]; ok {
+		return name
+	}
+	if key >= KeyF1 && key <= KeyF25 {
+		return fmt.Sprintf("F%d", key-KeyF1+1)
+	}
+	if key >= KeyApostrophe && key <= KeyGraveAccent {
+		// printable keys map to their ASCII character
+		return string(rune(key))
+	}
+	return fmt.Sprintf("Key %d", int(key))
+}
@@ -0,0 +1,21 @@
+package fam
+
+import "testing"
+
+func TestDefaultControlsDontClash(t *testing.T) {
+	if clashes := DefaultControls().KeyClashes(); len(clashes) > 0 {
+		t.Errorf("keys bound to the game and a key set: %v", clashes)
+	}
+}
+
+func TestAddPlayerWithAllKeySetsJoined(t *testing.T) {
+	w := NewWorld(1)
+	for range w.KeySets[:len(w.KeySets)-1] {
+		w.AddPlayer()
+	}
+	for _, bind := range w.Bindings[InputAddPlayer] {
+		if bind.Kind == BindKey && w.KeyInUse(Key(bind.Code)) {
+			t.Errorf("%v adds players but a keyboard player is using it", keyName(Key(bind.Code)))
+		}
+	}
+}
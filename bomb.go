@@ -14,6 +14,9 @@ type Bomb struct {
 
 	state bombState
 	time  float64
+	// hit are the players the explosion has reached, so each is only
+	// counted once while it lasts
+	hit map[*Player]bool
 }
 
 type bombState int
@@ -33,6 +36,7 @@ func NewBomb(pos cp.Vector, radius float64, space *cp.Space) *Bomb {
 	p := &Bomb{
 		Object: &eng.Object{},
 		state:  bombStateOk,
+		hit:    map[*Player]bool{},
 	}
 	p.Body = cp.NewBody(1, cp.MomentForCircle(1, radius, radius, cp.Vector{0, 0}))
 	// the bomb body is smaller because of the wick, so make it a little smaller
@@ -53,23 +57,24 @@ func NewBomb(pos cp.Vector, radius float64, space *cp.Space) *Bomb {
 
 const explosionSizeIncrease = 10
 
-func (p *Bomb) Update(g *Game, dt float64) {
+func (p *Bomb) Update(w *World, dt float64) {
 	if p.state == bombStateGone {
 		return
 	}
-	p.Object.Update(g.Space, dt, worldWidth, worldHeight)
+	p.Object.Update(w.Space, dt, w.Width, w.Height)
 	p.time += dt
 	if p.time > 5 && p.state != bombStateBoom {
 		p.state = bombStateBoom
 		p.Circle.SetRadius(p.Circle.Radius() * explosionSizeIncrease)
+		w.emit(Event{Kind: EventBoom, Pos: p.Position()})
 	}
 	if p.time > 5.2 && p.state == bombStateBoom {
 		p.Circle.SetRadius(p.Circle.Radius() / explosionSizeIncrease)
 	}
 	if p.time > 6 {
 		p.state = bombStateGone
-		g.Space.RemoveShape(p.Shape)
-		g.Space.RemoveBody(p.Body)
+		w.Space.RemoveShape(p.Shape)
+		w.Space.RemoveBody(p.Body)
 	}
 }
 
@@ -79,25 +84,26 @@ func (p *Bomb) Draw(g *Game, alpha float64) {
 	}
 
 	color := mgl32.Vec3{1, 1, 1}
-	var texture *eng.Texture2D
+	var texture string
 
 	switch p.state {
 	case bombStateOk:
-		texture = g.Texture(bombTexture)
+		texture = bombTexture
 		if int(p.time)%2 != 0 {
 			// flash of grey representing bomb ticking ala Zelda bombs
 			color = mgl32.Vec3{.5, .5, .5}
 		}
 	case bombStateBoom:
-		texture = g.Texture(bombPowTexture)
+		texture = bombPowTexture
 	default:
 		return
 	}
 
-	g.SpriteRenderer.DrawSprite(texture, p.SmoothPos(alpha), p.Size().Mul(2), p.Angle(), color)
+	g.drawSprite(texture, p.SmoothPos(alpha), p.Size().Mul(2), p.Angle(), color)
 }
 
 func BombPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
+	world := data.(*World)
 	a, b := arb.Shapes()
 
 	bomb := a.UserData.(*Bomb)
@@ -110,6 +116,11 @@ func BombPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
 	case *Player:
 		player := b.UserData.(*Player)
 		player.Circle.SetRadius(playerRadius)
+		if !bomb.hit[player] {
+			bomb.hit[player] = true
+			player.Stats.Bombed++
+			world.emit(Event{Kind: EventBombed, Pos: player.Position(), Player: player})
+		}
 	case *Bomb:
 		// since bombs are so light
 		multiplier = .25
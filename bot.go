@@ -0,0 +1,185 @@
+package fam
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// BotBehaviour picks what a bot cares about, most urgent first.
+type BotBehaviour int
+
+const (
+	// BotSmart flees bombs, then chases bananas, then wanders.
+	BotSmart BotBehaviour = iota
+	// BotChaser only chases bananas, bombs or not.
+	BotChaser
+	// BotScaredy runs from bombs and otherwise wanders.
+	BotScaredy
+	BotWanderer
+)
+
+var BotBehaviours = []BotBehaviour{BotSmart, BotChaser, BotScaredy, BotWanderer}
+
+var botBehaviourNames = []string{"Smart", "Chaser", "Scaredy", "Wanderer"}
+
+func (b BotBehaviour) String() string {
+	if b < 0 || int(b) >= len(botBehaviourNames) {
+		return "Bot"
+	}
+	return botBehaviourNames[b]
+}
+
+const (
+	// how close a ticking bomb has to be to run from it
+	botFleeRadius = 350.0
+	// how close a bomb has to be to jump over it
+	botBombJumpRadius = 120.0
+
+	// how long to hold jump, for a full height jump
+	botJumpTime = 0.25
+	// how long pushing against something counts as stuck
+	botStuckTime = 0.3
+)
+
+// Bot is an InputSource that plays by itself. It produces the same input a
+// controller would, so bots move exactly like people.
+type Bot struct {
+	Behaviour BotBehaviour
+
+	// Bots have their own random source: during a replay the recorded input
+	// is used and bots aren't polled, so they can't draw from World.Rand.
+	rand *rand.Rand
+
+	dir       float64
+	jumpTimer float64
+	stuck     float64
+}
+
+func NewBot(behaviour BotBehaviour, seed int64) *Bot {
+	b := &Bot{
+		Behaviour: behaviour,
+		rand:      rand.New(rand.NewSource(seed)),
+		dir:       1,
+	}
+	if b.rand.Intn(2) == 0 {
+		b.dir = -1
+	}
+	return b
+}
+
+func (b *Bot) Label(w *World) string {
+	return b.Behaviour.String() + " bot"
+}
+
+func (b *Bot) Poll(w *World) PlayerInput {
+	p := b.player(w)
+	if p == nil {
+		return PlayerInput{}
+	}
+	pos := p.Position()
+
+	var in PlayerInput
+	var acted bool
+	switch b.Behaviour {
+	case BotSmart:
+		acted = b.flee(w, pos, &in) || b.chase(w, pos, &in)
+	case BotChaser:
+		acted = b.chase(w, pos, &in)
+	case BotScaredy:
+		acted = b.flee(w, pos, &in)
+	}
+	if !acted {
+		b.wander(&in)
+	}
+
+	// jump when pushing against something, and turn around if that fails
+	if in.X != 0 && math.Abs(p.Velocity().X) < PlayerVelocity/20 {
+		b.stuck += eng.PhysicsDt
+	} else {
+		b.stuck = 0
+	}
+	if b.stuck > botStuckTime {
+		b.jump()
+		if b.stuck > 3*botStuckTime {
+			b.dir = -b.dir
+			b.stuck = 0
+		}
+	}
+
+	if b.jumpTimer > 0 {
+		b.jumpTimer -= eng.PhysicsDt
+		in.Jump = true
+	}
+	return in
+}
+
+// player finds the player this bot drives.
+func (b *Bot) player(w *World) *Player {
+	for _, p := range w.Players {
+		if p.Input == b {
+			return p
+		}
+	}
+	return nil
+}
+
+func (b *Bot) jump() {
+	if b.jumpTimer <= 0 {
+		b.jumpTimer = botJumpTime
+	}
+}
+
+// flee runs from the nearest ticking bomb and jumps it when there's no room.
+func (b *Bot) flee(w *World, pos cp.Vector, in *PlayerInput) bool {
+	var nearest *Bomb
+	dist := botFleeRadius
+	for _, bomb := range w.Bombs {
+		if bomb.state != bombStateOk {
+			continue
+		}
+		if d := bomb.Position().Distance(pos); d < dist {
+			nearest, dist = bomb, d
+		}
+	}
+	if nearest == nil {
+		return false
+	}
+	in.X = -math.Copysign(1, nearest.Position().X-pos.X)
+	if dist < botBombJumpRadius {
+		b.jump()
+	}
+	return true
+}
+
+// chase heads for the nearest banana, jumping for ones above.
+func (b *Bot) chase(w *World, pos cp.Vector, in *PlayerInput) bool {
+	var nearest *Banana
+	dist := math.Inf(1)
+	for _, banana := range w.Bananas {
+		if d := banana.Position().Distance(pos); d < dist {
+			nearest, dist = banana, d
+		}
+	}
+	if nearest == nil {
+		return false
+	}
+	delta := nearest.Position().Sub(pos)
+	if math.Abs(delta.X) > playerRadius/2 {
+		in.X = math.Copysign(1, delta.X)
+	}
+	if delta.Y < -2*playerRadius && math.Abs(delta.X) < JumpHeight {
+		b.jump()
+	}
+	return true
+}
+
+// wander walks one way until stuck against a wall, hopping now and then.
+func (b *Bot) wander(in *PlayerInput) {
+	in.X = b.dir
+	if b.rand.Float64() < eng.PhysicsDt/3 {
+		b.jump()
+	}
+}
@@ -0,0 +1,206 @@
+package fam
+
+import (
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const (
+	bumperRadius = 30.0
+	// bumperSpeed is how fast bumpers kick things away
+	bumperSpeed = 1000.0
+	// bumperFlash is how long a bumper lights up for when hit
+	bumperFlash = 0.15
+
+	springWidth = 80.0
+	// springRest is how high a spring pad's plate sits over its base, and
+	// springTravel how far it can be pushed down or fly up from there, which
+	// keeps it clear of the floor the pad stands on
+	springRest      = 45.0
+	springTravel    = 30.0
+	springMass      = 0.5
+	springStiffness = 2000.0
+	springDamping   = 5.0
+	// a plate squashed further than springTrigger throws what's on it up at
+	// springLaunchSpeed as it springs back
+	springTrigger     = 6.0
+	springLaunchSpeed = 1500.0
+)
+
+var (
+	bumperOutline = eng.FColor{.6, 0, .3, 1}
+	bumperFill    = eng.FColor{1, .3, .6, 1}
+	bumperLit     = eng.FColor{1, .9, .95, 1}
+	springColor   = eng.FColor{.7, .7, .75, 1}
+)
+
+// Bumper is a pinball bumper: a post that kicks away whatever touches it.
+type Bumper struct {
+	*cp.Shape
+	Pos cp.Vector
+
+	// lit counts down from bumperFlash after a hit
+	lit float64
+}
+
+// NewBumper makes a bumper at pos. Like walls, it isn't added to the space.
+func NewBumper(w *World, pos cp.Vector) *Bumper {
+	b := &Bumper{
+		Shape: cp.NewCircle(w.Space.StaticBody, bumperRadius, pos),
+		Pos:   pos,
+	}
+	b.SetElasticity(1)
+	b.SetFriction(0)
+	b.SetCollisionType(collisionBumper)
+	b.UserData = b
+	return b
+}
+
+func (b *Bumper) Update(dt float64) {
+	if b.lit > 0 {
+		b.lit -= dt
+	}
+}
+
+// BumperBegin kicks what touched a bumper straight away from it.
+func BumperBegin(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
+	world := data.(*World)
+	a, b := arb.Shapes()
+	bumper := a.UserData.(*Bumper)
+	body := b.Body()
+	if body.GetType() != cp.BODY_DYNAMIC {
+		return true
+	}
+	away := body.Position().Sub(bumper.Pos)
+	if away.LengthSq() == 0 {
+		away = cp.Vector{0, -1}
+	}
+	body.SetVelocityVector(away.Normalize().Mult(bumperSpeed))
+	bumper.lit = bumperFlash
+	world.emit(Event{Kind: EventBump, Pos: bumper.Pos.Add(away.Normalize().Mult(bumperRadius))})
+	return true
+}
+
+func (b *Bumper) Draw(g *Game) {
+	fill := bumperFill
+	if b.lit > 0 {
+		fill = bumperLit
+	}
+	g.CPRenderer.DrawCircle(b.Pos, 0, bumperRadius, bumperOutline, fill)
+	g.CPRenderer.DrawCircle(b.Pos, 0, bumperRadius/2, bumperOutline, bumperOutline)
+}
+
+// SpringPad is a plate on a damped spring over a fixed base. Landing on it
+// squashes the spring, which throws whoever landed high up. The plate is a
+// wall, so like other walls it can be jumped up through from below.
+type SpringPad struct {
+	// Base is the middle of the bottom of the pad, where the spring is tied.
+	Base  cp.Vector
+	Plate *Wall
+
+	spring, groove *cp.Constraint
+	// loaded is set while the plate is squashed enough to launch
+	loaded bool
+}
+
+// NewSpringPad makes a spring pad standing on base. Like walls, it isn't
+// added to the space.
+func NewSpringPad(w *World, base cp.Vector) *SpringPad {
+	body := cp.NewBody(springMass, cp.INFINITY)
+	body.SetPosition(base.Sub(cp.Vector{0, springRest}))
+	s := &SpringPad{
+		Base:  base,
+		Plate: newWall(body, cp.Vector{-springWidth / 2, 0}, cp.Vector{springWidth / 2, 0}),
+	}
+	s.Plate.SetFriction(wallFriction)
+	// gravity squashes the spring a little, so give it the length to make up
+	sag := springMass * Gravity / springStiffness
+	s.spring = cp.NewDampedSpring(w.Space.StaticBody, body, base, cp.Vector{}, springRest+sag, springStiffness, springDamping)
+	// the groove keeps the plate going straight up and down
+	top := base.Sub(cp.Vector{0, springRest + springTravel})
+	bottom := base.Sub(cp.Vector{0, springRest - springTravel})
+	s.groove = cp.NewGrooveJoint(w.Space.StaticBody, body, top, bottom, cp.Vector{})
+	return s
+}
+
+// Update launches whatever is on the plate once it springs back up past where
+// it rests.
+func (s *SpringPad) Update(w *World) {
+	plate := s.Plate.Body()
+	squash := plate.Position().Y - (s.Base.Y - springRest)
+	if squash > springTrigger {
+		s.loaded = true
+	}
+	if !s.loaded || squash > 0 || plate.Velocity().Y >= 0 {
+		return
+	}
+	s.loaded = false
+	plate.EachArbiter(func(arb *cp.Arbiter) {
+		_, other := arb.Shapes()
+		body := other.Body()
+		if arb.Normal().Y < -0.5 && body.GetType() == cp.BODY_DYNAMIC && body.Velocity().Y > -springLaunchSpeed {
+			body.SetVelocity(body.Velocity().X, -springLaunchSpeed)
+		}
+	})
+	w.emit(Event{Kind: EventBump, Pos: plate.Position()})
+}
+
+func (s *SpringPad) Draw(g *Game) {
+	a, b := s.Plate.Ends()
+	// the spring zigzags from the base up to the plate
+	const coils = 6
+	top := s.Plate.Body().Position()
+	prev := s.Base
+	for i := 1; i <= coils; i++ {
+		next := s.Base.Lerp(top, float64(i)/coils)
+		if i < coils {
+			side := 10.0
+			if i%2 == 0 {
+				side = -side
+			}
+			next.X += side
+		}
+		g.CPRenderer.DrawFatSegment(prev, next, 2, springColor, springColor)
+		prev = next
+	}
+	g.CPRenderer.DrawFatSegment(s.Base.Sub(cp.Vector{springWidth / 4, 0}), s.Base.Add(cp.Vector{springWidth / 4, 0}), wallWidth, eng.DefaultOutline, springColor)
+	props := s.Plate.Material.properties()
+	g.CPRenderer.DrawFatSegment(a, b, s.Plate.Radius(), props.outline, props.fill)
+}
+
+func (w *World) addBumper(b *Bumper) {
+	w.Bumpers = append(w.Bumpers, b)
+	w.Space.AddShape(b.Shape)
+}
+
+func (w *World) removeBumper(b *Bumper) {
+	for i := range w.Bumpers {
+		if w.Bumpers[i] == b {
+			w.Bumpers = append(w.Bumpers[:i], w.Bumpers[i+1:]...)
+			w.Space.RemoveShape(b.Shape)
+			return
+		}
+	}
+}
+
+func (w *World) addSpringPad(s *SpringPad) {
+	w.Springs = append(w.Springs, s)
+	w.Space.AddBody(s.Plate.Body())
+	w.Space.AddShape(s.Plate.Shape)
+	w.Space.AddConstraint(s.spring)
+	w.Space.AddConstraint(s.groove)
+}
+
+func (w *World) removeSpringPad(s *SpringPad) {
+	for i := range w.Springs {
+		if w.Springs[i] == s {
+			w.Springs = append(w.Springs[:i], w.Springs[i+1:]...)
+			w.releaseMouse(s.Plate.Body())
+			w.Space.RemoveConstraint(s.groove)
+			w.Space.RemoveConstraint(s.spring)
+			w.Space.RemoveShape(s.Plate.Shape)
+			w.Space.RemoveBody(s.Plate.Body())
+			return
+		}
+	}
+}
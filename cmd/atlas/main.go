@@ -0,0 +1,37 @@
+// Command atlas packs a directory of images into one texture atlas, written as
+// an image and a JSON manifest of where each image went. The game loads
+// assets/atlas.json when it's there and packs assets/textures itself when not.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/jakecoffman/fam/eng"
+)
+
+func main() {
+	out := flag.String("out", "assets/atlas", "write the atlas to this plus .png and the manifest to this plus .json")
+	width := flag.Int("width", eng.AtlasMaxWidth, "the widest the atlas can be")
+	height := flag.Int("height", eng.AtlasMaxHeight, "the tallest the atlas can be")
+	padding := flag.Int("padding", eng.AtlasPadding, "pixels around each image")
+	flag.Parse()
+
+	dir := "assets/textures"
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	images, err := eng.LoadImages(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	img, manifest, err := eng.PackAtlas(images, *width, *height, *padding)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = eng.WriteAtlas(*out, img, manifest); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("packed %d images into %dx%d", len(manifest.Regions), manifest.Width, manifest.Height)
+}
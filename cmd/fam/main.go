@@ -1,10 +1,39 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"time"
+
 	"github.com/jakecoffman/fam"
 	"github.com/jakecoffman/fam/eng"
 )
 
 func main() {
-	eng.Run(&fam.Game{})
+	headless := flag.Int("headless", 0, "step the simulation this many ticks without a window, then exit")
+	seed := flag.Int64("seed", 0, "seed for the simulation's random source, 0 picks one from the clock")
+	replayFile := flag.String("replay", "", "play back a recorded session")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	if *headless > 0 {
+		log.Println("Seed", *seed)
+		world := fam.NewWorld(*seed)
+		if *replayFile != "" {
+			replay, err := fam.LoadReplay(*replayFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			world.StartReplay(replay)
+		}
+		eng.RunHeadless(world, *headless)
+		log.Printf("ran %d ticks: %d players, %d bananas, %d bombs, %d walls",
+			*headless, len(world.Players), len(world.Bananas), len(world.Bombs), len(world.Walls))
+		return
+	}
+
+	eng.Run(&fam.Game{Seed: *seed, ReplayFile: *replayFile})
 }
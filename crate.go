@@ -0,0 +1,155 @@
+package fam
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const (
+	crateTexture = "block"
+	blockTexture = "block_solid"
+
+	crateSize = 40.0
+	crateMass = 5.0
+	// blockSize is the grid solid blocks are placed on in the editor
+	blockSize = 40.0
+)
+
+// crateSides are the shapes crates come in, picked at random. Boxes are
+// the most common, since they stack best.
+var crateSides = []int{4, 4, 4, 4, 3, 5, 6}
+
+var (
+	crateOutline = eng.FColor{.35, .2, .1, 1}
+	crateFill    = eng.FColor{.75, .5, .25, 1}
+)
+
+// Crate is a box, or some other polygon, that can be pushed about, dragged
+// with the mouse and stacked.
+type Crate struct {
+	*eng.Object
+	Poly *cp.PolyShape
+
+	// Sides is how many corners the crate has. Only boxes have a texture,
+	// the rest are drawn as polygons.
+	Sides int
+	Size  float64
+}
+
+func NewCrate(w *World, pos cp.Vector, sides int, size float64) *Crate {
+	// fewer sides than a triangle has no shape, and no size no mass
+	if sides < 3 {
+		sides = 4
+	}
+	if size <= 0 {
+		size = crateSize
+	}
+	c := &Crate{
+		Object: &eng.Object{},
+		Sides:  sides,
+		Size:   size,
+	}
+	if sides == 4 {
+		c.Body = cp.NewBody(crateMass, cp.MomentForBox(crateMass, size, size))
+		c.Shape = cp.NewBox(c.Body, size, size, 0)
+	} else {
+		// a regular polygon about as big as the box would be
+		verts := make([]cp.Vector, sides)
+		for i := range verts {
+			verts[i] = cp.ForAngle(2 * math.Pi * float64(i) / float64(sides)).Mult(size / 2 * math.Sqrt2)
+		}
+		c.Body = cp.NewBody(crateMass, cp.MomentForPoly(crateMass, sides, verts, cp.Vector{}, 0))
+		c.Shape = cp.NewPolyShape(c.Body, sides, verts, cp.NewTransformIdentity(), 0)
+	}
+	c.Shape.SetElasticity(0)
+	c.Shape.SetFriction(0.8)
+	c.Shape.UserData = c
+	c.Poly = c.Shape.Class.(*cp.PolyShape)
+
+	c.Body.SetPosition(pos)
+	w.Space.AddBody(c.Body)
+	w.Space.AddShape(c.Shape)
+	return c
+}
+
+// spawnCrate adds a crate of a random shape at pos.
+func (w *World) spawnCrate(pos cp.Vector) {
+	sides := crateSides[w.Rand.Intn(len(crateSides))]
+	w.Crates = append(w.Crates, NewCrate(w, pos, sides, crateSize))
+}
+
+func (c *Crate) Update(w *World, dt float64) {
+	c.Object.Update(w.Space, dt, w.Width, w.Height)
+}
+
+func (c *Crate) Draw(g *Game, alpha float64) {
+	angle := c.SmoothAngle(alpha)
+	if c.Sides == 4 {
+		size := float32(c.Size)
+		g.drawSprite(crateTexture, c.SmoothPos(alpha), mgl32.Vec2{size, size}, angle, eng.White)
+		return
+	}
+	pos := c.SmoothPos(alpha)
+	center, rot := cp.Vector{float64(pos.X()), float64(pos.Y())}, cp.ForAngle(angle)
+	verts := make([]cp.Vector, c.Poly.Count())
+	for i := range verts {
+		verts[i] = center.Add(rot.Rotate(c.Poly.Vert(i)))
+	}
+	g.CPRenderer.DrawPolygon(len(verts), verts, 0, crateOutline, crateFill)
+}
+
+// Block is a solid square of level that doesn't move, like a wall that
+// takes up room.
+type Block struct {
+	*cp.Shape
+	BB cp.BB
+}
+
+// NewBlock makes a block filling bb. Like walls, it isn't added to the space.
+func NewBlock(w *World, bb cp.BB) *Block {
+	b := &Block{
+		Shape: cp.NewBox2(w.Space.StaticBody, bb, 0),
+		BB:    bb,
+	}
+	b.SetElasticity(wallElasticity)
+	b.SetFriction(wallFriction)
+	b.UserData = b
+	return b
+}
+
+// blockAt is the square of the block grid pos is in.
+func blockAt(pos cp.Vector) cp.BB {
+	x := math.Floor(pos.X/blockSize) * blockSize
+	y := math.Floor(pos.Y/blockSize) * blockSize
+	return cp.NewBB(x, y, x+blockSize, y+blockSize)
+}
+
+func (b *Block) Draw(g *Game, alpha float64) {
+	center := b.BB.Center()
+	size := mgl32.Vec2{float32(b.BB.R - b.BB.L), float32(b.BB.T - b.BB.B)}
+	g.drawSprite(blockTexture, eng.V(center), size, 0, eng.White)
+}
+
+// insertBlock puts a block into the world at index i of Blocks.
+func (w *World) insertBlock(i int, b *Block) {
+	w.Blocks = append(w.Blocks, nil)
+	copy(w.Blocks[i+1:], w.Blocks[i:])
+	w.Blocks[i] = b
+	w.Space.AddShape(b.Shape)
+}
+
+// removeBlock takes a block out of the world and returns the index it had in
+// Blocks, or -1 if it wasn't there.
+func (w *World) removeBlock(b *Block) int {
+	for i := range w.Blocks {
+		if w.Blocks[i] == b {
+			w.Blocks = append(w.Blocks[:i], w.Blocks[i+1:]...)
+			w.Space.RemoveShape(b.Shape)
+			return i
+		}
+	}
+	return -1
+}
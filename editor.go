@@ -0,0 +1,705 @@
+package fam
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const (
+	editorGrid = 20.0
+
+	// how close the mouse has to be to grab a wall or one of its endpoints
+	editorGrabRadius = 10.0
+
+	editorHistoryLimit = 100
+)
+
+var (
+	editorSelectedFill = eng.FColor{1, .8, .2, 1}
+	editorHandleFill   = eng.FColor{1, 1, 1, 1}
+	editorBoxOutline   = eng.FColor{1, .8, .2, 1}
+	editorGridColor    = eng.FColor{1, 1, 1, .1}
+)
+
+// editCommand is one undoable change to the walls of a world.
+type editCommand interface {
+	do(w *World)
+	undo(w *World)
+}
+
+// editHistory holds the commands that can be undone and redone.
+type editHistory struct {
+	done, undone []editCommand
+}
+
+// run does cmd and records it.
+func (h *editHistory) run(w *World, cmd editCommand) {
+	cmd.do(w)
+	h.push(cmd)
+}
+
+// push records a command that has already been done.
+func (h *editHistory) push(cmd editCommand) {
+	h.done = append(h.done, cmd)
+	if len(h.done) > editorHistoryLimit {
+		h.done = append(h.done[:0], h.done[1:]...)
+	}
+	h.undone = nil
+}
+
+func (h *editHistory) undo(w *World) {
+	if len(h.done) == 0 {
+		return
+	}
+	cmd := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+	cmd.undo(w)
+	h.undone = append(h.undone, cmd)
+}
+
+func (h *editHistory) redo(w *World) {
+	if len(h.undone) == 0 {
+		return
+	}
+	cmd := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+	cmd.do(w)
+	h.done = append(h.done, cmd)
+}
+
+func (h *editHistory) clear() {
+	h.done = nil
+	h.undone = nil
+}
+
+type addWallCommand struct {
+	wall *Wall
+}
+
+func (c *addWallCommand) do(w *World) {
+	w.insertWall(len(w.Walls), c.wall)
+}
+
+func (c *addWallCommand) undo(w *World) {
+	w.removeWall(c.wall)
+}
+
+type deleteWallsCommand struct {
+	walls []*Wall
+	index []int
+}
+
+func (c *deleteWallsCommand) do(w *World) {
+	c.index = c.index[:0]
+	for _, wall := range c.walls {
+		c.index = append(c.index, w.removeWall(wall))
+	}
+}
+
+func (c *deleteWallsCommand) undo(w *World) {
+	for i := len(c.walls) - 1; i >= 0; i-- {
+		if c.index[i] >= 0 {
+			w.insertWall(c.index[i], c.walls[i])
+		}
+	}
+}
+
+type wallEnds struct {
+	A, B cp.Vector
+}
+
+type moveWallsCommand struct {
+	walls    []*Wall
+	from, to []wallEnds
+}
+
+func (c *moveWallsCommand) do(w *World) {
+	for i, wall := range c.walls {
+		w.moveWall(wall, c.to[i].A, c.to[i].B)
+	}
+}
+
+func (c *moveWallsCommand) undo(w *World) {
+	for i, wall := range c.walls {
+		w.moveWall(wall, c.from[i].A, c.from[i].B)
+	}
+}
+
+// materialCommand changes what walls are made of.
+type materialCommand struct {
+	walls []*Wall
+	from  []WallMaterial
+	to    WallMaterial
+}
+
+func (c *materialCommand) do(w *World) {
+	c.from = c.from[:0]
+	for _, wall := range c.walls {
+		c.from = append(c.from, wall.Material)
+		wall.SetMaterial(c.to)
+	}
+}
+
+func (c *materialCommand) undo(w *World) {
+	for i, wall := range c.walls {
+		wall.SetMaterial(c.from[i])
+	}
+}
+
+type addPlatformCommand struct {
+	platform *MovingPlatform
+}
+
+func (c *addPlatformCommand) do(w *World) {
+	w.insertPlatform(len(w.Platforms), c.platform)
+}
+
+func (c *addPlatformCommand) undo(w *World) {
+	w.removePlatform(c.platform)
+}
+
+type deletePlatformCommand struct {
+	platform *MovingPlatform
+	index    int
+}
+
+func (c *deletePlatformCommand) do(w *World) {
+	c.index = w.removePlatform(c.platform)
+}
+
+func (c *deletePlatformCommand) undo(w *World) {
+	if c.index >= 0 {
+		w.insertPlatform(c.index, c.platform)
+	}
+}
+
+// platformMove is where and how a platform goes.
+type platformMove struct {
+	Path   []cp.Vector
+	Motion PlatformMotion
+	Speed  float64
+}
+
+func (p *MovingPlatform) move() platformMove {
+	return platformMove{append([]cp.Vector(nil), p.Path...), p.Motion, p.Speed}
+}
+
+func (p *MovingPlatform) setMove(m platformMove) {
+	p.Path = append(p.Path[:0], m.Path...)
+	p.Motion = m.Motion
+	p.Speed = m.Speed
+	p.Reset()
+}
+
+// platformCommand changes a platform's path, motion or speed.
+type platformCommand struct {
+	platform *MovingPlatform
+	from, to platformMove
+}
+
+func (c *platformCommand) do(w *World) {
+	c.platform.setMove(c.to)
+}
+
+func (c *platformCommand) undo(w *World) {
+	c.platform.setMove(c.from)
+}
+
+// blockCommand adds a block, or removes it when remove is set.
+type blockCommand struct {
+	block  *Block
+	remove bool
+	index  int
+}
+
+func (c *blockCommand) do(w *World) {
+	if c.remove {
+		c.index = w.removeBlock(c.block)
+	} else {
+		w.insertBlock(len(w.Blocks), c.block)
+	}
+}
+
+func (c *blockCommand) undo(w *World) {
+	if !c.remove {
+		w.removeBlock(c.block)
+	} else if c.index >= 0 {
+		w.insertBlock(c.index, c.block)
+	}
+}
+
+// ropeCommand adds a rope, or removes it when remove is set.
+type ropeCommand struct {
+	rope   *Rope
+	remove bool
+	index  int
+}
+
+func (c *ropeCommand) do(w *World) {
+	if c.remove {
+		c.index = w.removeRope(c.rope)
+	} else {
+		w.insertRope(len(w.Ropes), c.rope)
+	}
+}
+
+func (c *ropeCommand) undo(w *World) {
+	if !c.remove {
+		w.removeRope(c.rope)
+	} else if c.index >= 0 {
+		w.insertRope(c.index, c.rope)
+	}
+}
+
+type editorDrag int
+
+const (
+	editorDragNone editorDrag = iota
+	editorDragBox
+	editorDragEndpoint
+	editorDragMove
+	editorDragDraw
+	editorDragWaypoint
+	editorDragPath
+)
+
+// Editor is the level editing tool used in stateEdit. Left click selects
+// walls (shift adds to the selection) and drags them or their endpoints, left
+// drag on empty space box-selects and right drag draws a new wall, or a new
+// platform when drawPlatforms is on. A selected platform shows its path,
+// which drags as a whole or by its waypoints.
+type Editor struct {
+	selected []*Wall
+	platform *MovingPlatform
+	snap     bool
+	// material is what new walls are made of, in the editor and in play
+	material      WallMaterial
+	drawPlatforms bool
+
+	drag      editorDrag
+	dragStart cp.Vector
+	dragEnd   int // 0 for A, 1 for B when dragging an endpoint
+	dragFrom  []wallEnds
+	dragMouse cp.Vector
+	newWall   *Wall
+	// dragPoint is the waypoint being dragged, and dragPath the platform's
+	// path before the drag
+	dragPoint int
+	dragPath  platformMove
+}
+
+func (e *Editor) snapped(v cp.Vector) cp.Vector {
+	if !e.snap {
+		return v
+	}
+	return cp.Vector{math.Round(v.X/editorGrid) * editorGrid, math.Round(v.Y/editorGrid) * editorGrid}
+}
+
+func (e *Editor) isSelected(wall *Wall) bool {
+	for _, s := range e.selected {
+		if s == wall {
+			return true
+		}
+	}
+	return false
+}
+
+// wallAt returns the wall under pos, if any.
+func (e *Editor) wallAt(w *World, pos cp.Vector) *Wall {
+	for i := len(w.Walls) - 1; i >= 0; i-- {
+		wall := w.Walls[i]
+		if pos.ClosestPointOnSegment(wall.A(), wall.B()).Distance(pos) <= wall.Radius()+editorGrabRadius {
+			return wall
+		}
+	}
+	return nil
+}
+
+// endpointAt returns a selected wall with an endpoint under pos and which end it is.
+func (e *Editor) endpointAt(pos cp.Vector) (*Wall, int) {
+	for _, wall := range e.selected {
+		if wall.A().Distance(pos) <= editorGrabRadius {
+			return wall, 0
+		}
+		if wall.B().Distance(pos) <= editorGrabRadius {
+			return wall, 1
+		}
+	}
+	return nil, 0
+}
+
+// platformAt returns the platform under pos, if any, grabbing it by its
+// wall or the start of its path.
+func (e *Editor) platformAt(w *World, pos cp.Vector) *MovingPlatform {
+	for i := len(w.Platforms) - 1; i >= 0; i-- {
+		p := w.Platforms[i]
+		a, b := p.Ends()
+		if pos.ClosestPointOnSegment(a, b).Distance(pos) <= p.Radius()+editorGrabRadius || p.Path[0].Distance(pos) <= editorGrabRadius {
+			return p
+		}
+	}
+	return nil
+}
+
+// waypointAt returns which waypoint of the selected platform is under pos, or
+// -1.
+func (e *Editor) waypointAt(pos cp.Vector) int {
+	if e.platform == nil {
+		return -1
+	}
+	for i := len(e.platform.Path) - 1; i >= 0; i-- {
+		if e.platform.Path[i].Distance(pos) <= editorGrabRadius {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Editor) Press(w *World, pos cp.Vector, shift bool) {
+	e.dragStart = pos
+	e.dragMouse = pos
+
+	if i := e.waypointAt(pos); i >= 0 {
+		e.drag = editorDragWaypoint
+		e.dragPoint = i
+		e.dragPath = e.platform.move()
+		return
+	}
+	if p := e.platformAt(w, pos); p != nil {
+		e.selected = nil
+		e.platform = p
+		e.drag = editorDragPath
+		e.dragPath = p.move()
+		return
+	}
+	e.platform = nil
+
+	if wall, end := e.endpointAt(pos); wall != nil {
+		e.selected = []*Wall{wall}
+		e.drag = editorDragEndpoint
+		e.dragEnd = end
+		e.dragFrom = []wallEnds{{wall.A(), wall.B()}}
+		return
+	}
+
+	wall := e.wallAt(w, pos)
+	if wall == nil {
+		if !shift {
+			e.selected = nil
+		}
+		e.drag = editorDragBox
+		return
+	}
+
+	if !e.isSelected(wall) {
+		if shift {
+			e.selected = append(e.selected, wall)
+		} else {
+			e.selected = []*Wall{wall}
+		}
+	}
+	e.drag = editorDragMove
+	e.dragFrom = e.dragFrom[:0]
+	for _, s := range e.selected {
+		e.dragFrom = append(e.dragFrom, wallEnds{s.A(), s.B()})
+	}
+}
+
+func (e *Editor) RightPress(w *World, pos cp.Vector) {
+	if e.drag != editorDragNone {
+		return
+	}
+	start := e.snapped(pos)
+	e.drag = editorDragDraw
+	e.dragStart = start
+	e.newWall = NewWall(w, start, start)
+	e.newWall.SetMaterial(e.material)
+}
+
+func (e *Editor) Moved(w *World, pos cp.Vector) {
+	e.dragMouse = pos
+
+	switch e.drag {
+	case editorDragEndpoint:
+		wall, from := e.selected[0], e.dragFrom[0]
+		if e.dragEnd == 0 {
+			w.moveWall(wall, e.snapped(pos), from.B)
+		} else {
+			w.moveWall(wall, from.A, e.snapped(pos))
+		}
+	case editorDragMove:
+		// snap the grabbed point so the selection moves in whole grid steps
+		delta := e.snapped(pos.Sub(e.dragStart))
+		for i, wall := range e.selected {
+			w.moveWall(wall, e.dragFrom[i].A.Add(delta), e.dragFrom[i].B.Add(delta))
+		}
+	case editorDragDraw:
+		e.newWall.SetEndpoints(e.dragStart, e.snapped(pos))
+	case editorDragWaypoint:
+		e.platform.Path[e.dragPoint] = e.snapped(pos)
+		e.platform.Reset()
+	case editorDragPath:
+		delta := e.snapped(pos.Sub(e.dragStart))
+		for i, point := range e.dragPath.Path {
+			e.platform.Path[i] = point.Add(delta)
+		}
+		e.platform.Reset()
+	}
+}
+
+// Release finishes whatever drag is in progress.
+func (e *Editor) Release(w *World, pos cp.Vector) {
+	e.Moved(w, pos)
+
+	switch e.drag {
+	case editorDragBox:
+		box := e.box()
+		for _, wall := range w.Walls {
+			if box.IntersectsSegment(wall.A(), wall.B()) && !e.isSelected(wall) {
+				e.selected = append(e.selected, wall)
+			}
+		}
+	case editorDragEndpoint, editorDragMove:
+		cmd := &moveWallsCommand{
+			walls: append([]*Wall(nil), e.selected...),
+			from:  append([]wallEnds(nil), e.dragFrom...),
+		}
+		moved := false
+		for i, wall := range cmd.walls {
+			cmd.to = append(cmd.to, wallEnds{wall.A(), wall.B()})
+			moved = moved || cmd.to[i] != cmd.from[i]
+		}
+		if moved {
+			w.history.push(cmd)
+		}
+	case editorDragDraw:
+		switch {
+		case e.newWall.A() == e.newWall.B():
+		case e.drawPlatforms:
+			p := NewMovingPlatform(e.newWall.A(), e.newWall.B())
+			p.SetMaterial(e.material)
+			w.history.run(w, &addPlatformCommand{p})
+			e.selected, e.platform = nil, p
+		default:
+			w.history.run(w, &addWallCommand{e.newWall})
+			e.selected, e.platform = []*Wall{e.newWall}, nil
+		}
+		e.newWall = nil
+	case editorDragWaypoint, editorDragPath:
+		e.changePlatform(w, e.dragPath)
+	}
+	e.drag = editorDragNone
+}
+
+func (e *Editor) LeftRelease(w *World, pos cp.Vector) {
+	if e.drag != editorDragDraw {
+		e.Release(w, pos)
+	}
+}
+
+func (e *Editor) RightRelease(w *World, pos cp.Vector) {
+	if e.drag == editorDragDraw {
+		e.Release(w, pos)
+	}
+}
+
+func (e *Editor) box() cp.BB {
+	a, b := e.dragStart, e.dragMouse
+	return cp.NewBB(math.Min(a.X, b.X), math.Min(a.Y, b.Y), math.Max(a.X, b.X), math.Max(a.Y, b.Y))
+}
+
+// Key handles the editor's keyboard shortcuts.
+func (e *Editor) Key(w *World, key Key, mods glfw.ModifierKey) {
+	switch {
+	case key == KeyDelete || key == KeyBackspace:
+		e.DeleteSelected(w)
+	case key == KeyZ && mods&glfw.ModControl != 0 && mods&glfw.ModShift != 0:
+		e.Redo(w)
+	case key == KeyZ && mods&glfw.ModControl != 0:
+		e.Undo(w)
+	case key == KeyY && mods&glfw.ModControl != 0:
+		e.Redo(w)
+	case key == KeyG:
+		e.snap = !e.snap
+	case key == KeyM:
+		e.NextMaterial(w)
+	case key == KeyP:
+		e.drawPlatforms = !e.drawPlatforms
+	case key == KeyB:
+		e.ToggleBlock(w)
+	case key == KeyR:
+		e.ToggleRope(w, mods&glfw.ModShift != 0)
+	case key == KeyW:
+		e.AddWaypoint(w)
+	case key == KeyO:
+		e.NextMotion(w)
+	case key == KeyLeftBracket:
+		e.ChangeSpeed(w, 1/1.25)
+	case key == KeyRightBracket:
+		e.ChangeSpeed(w, 1.25)
+	}
+}
+
+// ToggleBlock fills the square of the block grid under the mouse with a
+// block, or empties it if there's one there already.
+func (e *Editor) ToggleBlock(w *World) {
+	if e.drag != editorDragNone {
+		return
+	}
+	bb := blockAt(e.dragMouse)
+	for _, block := range w.Blocks {
+		if block.BB == bb {
+			w.history.run(w, &blockCommand{block: block, remove: true})
+			return
+		}
+	}
+	w.history.run(w, &blockCommand{block: NewBlock(w, bb)})
+}
+
+// ToggleRope hangs a rope, or a chain, from the wall nearest the mouse, or
+// takes away the rope hanging there already.
+func (e *Editor) ToggleRope(w *World, chain bool) {
+	if e.drag != editorDragNone {
+		return
+	}
+	anchor := e.snapped(e.dragMouse)
+	for _, rope := range w.Ropes {
+		if rope.Anchor.Distance(anchor) <= editorGrabRadius {
+			w.history.run(w, &ropeCommand{rope: rope, remove: true})
+			return
+		}
+	}
+	// tie it to the wall if there's one close by
+	if wall := e.wallAt(w, anchor); wall != nil {
+		anchor = anchor.ClosestPointOnSegment(wall.A(), wall.B())
+	}
+	w.history.run(w, &ropeCommand{rope: NewRope(w, anchor, ropeLength, chain)})
+}
+
+// changePlatform records the change to the selected platform since it was
+// as from.
+func (e *Editor) changePlatform(w *World, from platformMove) {
+	to := e.platform.move()
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+	w.history.push(&platformCommand{platform: e.platform, from: from, to: to})
+}
+
+// AddWaypoint adds the mouse to the end of the selected platform's path.
+func (e *Editor) AddWaypoint(w *World) {
+	if e.platform == nil || e.drag != editorDragNone {
+		return
+	}
+	from := e.platform.move()
+	e.platform.Path = append(e.platform.Path, e.snapped(e.dragMouse))
+	e.platform.Reset()
+	e.changePlatform(w, from)
+}
+
+// NextMotion switches the selected platform to the next way of moving.
+// Speeds are in different units spinning, so they go back to the default.
+func (e *Editor) NextMotion(w *World) {
+	if e.platform == nil || e.drag != editorDragNone {
+		return
+	}
+	from := e.platform.move()
+	e.platform.Motion = (e.platform.Motion + 1) % PlatformMotion(len(platformMotionNames))
+	switch {
+	case e.platform.Motion == MotionSpin:
+		e.platform.Speed = platformSpin
+	case from.Motion == MotionSpin:
+		e.platform.Speed = platformSpeed
+	}
+	e.platform.Reset()
+	e.changePlatform(w, from)
+}
+
+// ChangeSpeed multiplies the selected platform's speed by scale.
+func (e *Editor) ChangeSpeed(w *World, scale float64) {
+	if e.platform == nil || e.drag != editorDragNone {
+		return
+	}
+	from := e.platform.move()
+	e.platform.Speed *= scale
+	e.changePlatform(w, from)
+}
+
+// NextMaterial moves on to the next material, for new walls and for the
+// walls selected.
+func (e *Editor) NextMaterial(w *World) {
+	if e.drag != editorDragNone {
+		return
+	}
+	e.material = (e.material + 1) % WallMaterial(len(WallMaterials))
+	if len(e.selected) > 0 {
+		w.history.run(w, &materialCommand{walls: append([]*Wall(nil), e.selected...), to: e.material})
+	}
+	if e.platform != nil {
+		w.history.run(w, &materialCommand{walls: []*Wall{e.platform.Wall}, to: e.material})
+	}
+}
+
+func (e *Editor) DeleteSelected(w *World) {
+	if e.drag != editorDragNone {
+		return
+	}
+	if e.platform != nil {
+		w.history.run(w, &deletePlatformCommand{platform: e.platform})
+		e.platform = nil
+	}
+	if len(e.selected) > 0 {
+		w.history.run(w, &deleteWallsCommand{walls: e.selected})
+		e.selected = nil
+	}
+}
+
+func (e *Editor) Undo(w *World) {
+	if e.drag != editorDragNone {
+		return
+	}
+	w.history.undo(w)
+	e.selected, e.platform = nil, nil
+}
+
+func (e *Editor) Redo(w *World) {
+	if e.drag != editorDragNone {
+		return
+	}
+	w.history.redo(w)
+	e.selected, e.platform = nil, nil
+}
+
+// Draw overlays the grid, selection and handles on the walls.
+func (e *Editor) Draw(g *Game) {
+	if e.snap {
+		for x := 0.0; x <= g.Width; x += editorGrid {
+			g.CPRenderer.DrawSegment(cp.Vector{x, 0}, cp.Vector{x, g.Height}, editorGridColor)
+		}
+		for y := 0.0; y <= g.Height; y += editorGrid {
+			g.CPRenderer.DrawSegment(cp.Vector{0, y}, cp.Vector{g.Width, y}, editorGridColor)
+		}
+	}
+	for _, wall := range e.selected {
+		g.CPRenderer.DrawFatSegment(wall.A(), wall.B(), wall.Radius(), eng.DefaultOutline, editorSelectedFill)
+		g.CPRenderer.DrawDot(editorGrabRadius, wall.A(), editorHandleFill)
+		g.CPRenderer.DrawDot(editorGrabRadius, wall.B(), editorHandleFill)
+	}
+	if p := e.platform; p != nil {
+		a, b := p.Ends()
+		g.CPRenderer.DrawFatSegment(a, b, p.Radius(), eng.DefaultOutline, editorSelectedFill)
+		for _, point := range p.Path {
+			g.CPRenderer.DrawDot(editorGrabRadius, point, editorHandleFill)
+		}
+	}
+	if e.newWall != nil {
+		g.CPRenderer.DrawFatSegment(e.newWall.A(), e.newWall.B(), e.newWall.Radius(), eng.DefaultOutline, editorSelectedFill)
+	}
+	if e.drag == editorDragBox {
+		g.CPRenderer.DrawBB(e.box(), editorBoxOutline)
+	}
+}
@@ -0,0 +1,81 @@
+package fam
+
+import (
+	"log"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// emittersFile holds the particle emitters, by name.
+const emittersFile = "assets/emitters.json"
+
+// loadEmitters reads the particle emitters. Without them the game still runs,
+// just without particles.
+func (g *Game) loadEmitters() {
+	emitters, err := eng.LoadEmitters(emittersFile)
+	if err != nil {
+		log.Println(err)
+		emitters = map[string]*eng.Emitter{}
+	}
+	g.emitters = emitters
+}
+
+func (g *Game) saveEmitters() {
+	if err := eng.SaveEmitters(emittersFile, g.emitters); err != nil {
+		log.Println(err)
+	}
+}
+
+// burst gives off the named emitter's particles at pos.
+func (g *Game) burst(name string, pos cp.Vector) {
+	if e, ok := g.emitters[name]; ok {
+		g.ParticleGenerator.Emit(e, eng.V(pos))
+	}
+}
+
+// effects turns the world's events from the last tick into particles and
+// player expressions, and gives off the particles of everything with
+// emitters attached.
+func (g *Game) effects(dt float64) {
+	for _, e := range g.Events {
+		switch e.Kind {
+		case EventEat:
+			g.burst("sparkle", e.Pos)
+			e.Player.look.eat()
+		case EventBoom:
+			g.burst("smoke", e.Pos)
+		case EventLand:
+			g.burst("dust", e.Pos)
+			e.Player.look.land()
+		case EventBombed:
+			e.Player.look.bombed()
+		case EventJump:
+			e.Player.look.jump()
+		case EventBump:
+			g.burst("sparkle", e.Pos)
+		}
+	}
+	for _, p := range g.Players {
+		p.look.update(p, dt)
+	}
+
+	// bombs spark from the fuse until they go off; the body sits at the wick
+	fuse := g.emitters["fuse"]
+	for _, b := range g.Bombs {
+		if fuse != nil {
+			if b.state == bombStateOk {
+				b.Attach(fuse, cp.Vector{})
+			} else {
+				b.Detach(fuse)
+			}
+		}
+		g.ParticleGenerator.EmitFrom(b.Object, dt)
+	}
+	for _, b := range g.Bananas {
+		g.ParticleGenerator.EmitFrom(b.Object, dt)
+	}
+	for _, p := range g.Players {
+		g.ParticleGenerator.EmitFrom(p.Object, dt)
+	}
+}
@@ -0,0 +1,121 @@
+package eng
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LoopMode is what an animation does once it reaches its last frame.
+type LoopMode int
+
+const (
+	// LoopOnce stops on the last frame.
+	LoopOnce LoopMode = iota
+	// LoopRepeat starts over from the first frame.
+	LoopRepeat
+	// LoopPingPong plays backwards to the first frame, then forwards again.
+	LoopPingPong
+)
+
+// Frame is one step of an animation.
+type Frame struct {
+	// Texture names the image shown, empty to leave it to another animation.
+	Texture string
+	// Duration is how long the frame shows, in seconds.
+	Duration float64
+	// Scale squashes and stretches the sprite; zero is its normal size.
+	Scale mgl32.Vec2
+	// Rotate turns the sprite this many radians more.
+	Rotate float64
+}
+
+// Size is size scaled by the frame.
+func (f Frame) Size(size mgl32.Vec2) mgl32.Vec2 {
+	if f.Scale == (mgl32.Vec2{}) {
+		return size
+	}
+	return mgl32.Vec2{size.X() * f.Scale.X(), size.Y() * f.Scale.Y()}
+}
+
+type Animation struct {
+	Frames []Frame
+	Loop   LoopMode
+}
+
+// Duration is how long one play through the frames takes.
+func (a *Animation) Duration() float64 {
+	var total float64
+	for _, f := range a.Frames {
+		total += f.Duration
+	}
+	return total
+}
+
+// Frame is the frame showing t seconds after the animation started.
+func (a *Animation) Frame(t float64) Frame {
+	if len(a.Frames) == 0 {
+		return Frame{}
+	}
+	total := a.Duration()
+	if total <= 0 {
+		return a.Frames[0]
+	}
+	switch a.Loop {
+	case LoopRepeat:
+		t = math.Mod(t, total)
+	case LoopPingPong:
+		t = math.Mod(t, 2*total)
+		if t >= total {
+			t = 2*total - t
+		}
+	}
+	for _, f := range a.Frames {
+		if t < f.Duration {
+			return f
+		}
+		t -= f.Duration
+	}
+	return a.Frames[len(a.Frames)-1]
+}
+
+// Done reports whether an animation that plays once has finished t seconds
+// after it started. Looping animations never finish.
+func (a *Animation) Done(t float64) bool {
+	return a.Loop == LoopOnce && t >= a.Duration()
+}
+
+// Animator plays one animation at a time, keeping track of how far in it is.
+type Animator struct {
+	Animation *Animation
+	Time      float64
+}
+
+// Play switches to anim from its start, unless it's already playing.
+func (a *Animator) Play(anim *Animation) {
+	if a.Animation != anim {
+		a.Restart(anim)
+	}
+}
+
+// Restart plays anim from its start, even if it's already playing.
+func (a *Animator) Restart(anim *Animation) {
+	a.Animation = anim
+	a.Time = 0
+}
+
+func (a *Animator) Update(dt float64) {
+	a.Time += dt
+}
+
+// Frame is the frame showing now, the zero frame when nothing is playing.
+func (a *Animator) Frame() Frame {
+	if a.Animation == nil {
+		return Frame{}
+	}
+	return a.Animation.Frame(a.Time)
+}
+
+func (a *Animator) Done() bool {
+	return a.Animation == nil || a.Animation.Done(a.Time)
+}
@@ -0,0 +1,222 @@
+package eng
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// AtlasMaxWidth and AtlasMaxHeight are as big as atlases get. GL 3.3 only
+	// guarantees textures up to 1024, so the game also keeps them within
+	// MaxTextureSize.
+	AtlasMaxWidth  = 4096
+	AtlasMaxHeight = 4096
+	// AtlasPadding is the gap around each image, filled with its edge pixels
+	// so filtering doesn't bleed in its neighbours.
+	AtlasPadding = 2
+)
+
+// Region is where one image sits in an atlas, in pixels from the top left.
+type Region struct {
+	X, Y, W, H int
+}
+
+// AtlasManifest is the JSON saved next to an atlas image.
+type AtlasManifest struct {
+	// Image is the atlas image's file name, next to the manifest.
+	Image         string
+	Width, Height int
+	Regions       map[string]Region
+}
+
+// Atlas is many images packed into one texture, so sprites drawn from it
+// share a texture bind and a draw call.
+type Atlas struct {
+	Texture *Texture2D
+	AtlasManifest
+}
+
+// MaxTextureSize is the widest and tallest texture the GL driver takes.
+func MaxTextureSize() int {
+	var size int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_SIZE, &size)
+	return int(size)
+}
+
+// NewAtlas uploads a packed atlas image.
+func NewAtlas(img image.Image, manifest AtlasManifest) *Atlas {
+	texture := NewTexture()
+	// regions run to the edge of the image, so don't wrap around
+	texture.WrapS = gl.CLAMP_TO_EDGE
+	texture.WrapT = gl.CLAMP_TO_EDGE
+	texture.GenerateFromImage(img)
+	return &Atlas{Texture: texture, AtlasManifest: manifest}
+}
+
+// BuildAtlas packs every image in dir into an atlas.
+func BuildAtlas(dir string) (*Atlas, error) {
+	images, err := LoadImages(dir)
+	if err != nil {
+		return nil, err
+	}
+	size := MaxTextureSize()
+	img, manifest, err := PackAtlas(images, min(AtlasMaxWidth, size), min(AtlasMaxHeight, size), AtlasPadding)
+	if err != nil {
+		return nil, err
+	}
+	return NewAtlas(img, manifest), nil
+}
+
+// LoadAtlas reads an atlas written by WriteAtlas, given its manifest.
+func LoadAtlas(manifestFile string) (*Atlas, error) {
+	data, err := os.ReadFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest AtlasManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%v: %v", manifestFile, err)
+	}
+	if size := MaxTextureSize(); manifest.Width > size || manifest.Height > size {
+		return nil, fmt.Errorf("%v: %dx%d is bigger than the %dpx textures this GPU takes", manifestFile, manifest.Width, manifest.Height, size)
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(manifestFile), manifest.Image))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", manifest.Image, err)
+	}
+	return NewAtlas(img, manifest), nil
+}
+
+// UV is the region's texture coordinates: left, top, right and bottom.
+func (a *Atlas) UV(name string) (mgl32.Vec4, bool) {
+	r, ok := a.Regions[name]
+	if !ok {
+		return mgl32.Vec4{}, false
+	}
+	w, h := float32(a.Width), float32(a.Height)
+	return mgl32.Vec4{float32(r.X) / w, float32(r.Y) / h, float32(r.X+r.W) / w, float32(r.Y+r.H) / h}, true
+}
+
+// LoadImages decodes every image in dir, named by file name without the
+// extension, like the textures the game loads.
+func LoadImages(dir string) (map[string]image.Image, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	images := map[string]image.Image{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", entry.Name(), err)
+		}
+		images[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = img
+	}
+	return images, nil
+}
+
+// PackAtlas packs images onto shelves, tallest first, in an image no wider
+// than maxWidth and no taller than maxHeight.
+func PackAtlas(images map[string]image.Image, maxWidth, maxHeight, padding int) (*image.RGBA, AtlasManifest, error) {
+	var names []string
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		hi, hj := images[names[i]].Bounds().Dy(), images[names[j]].Bounds().Dy()
+		if hi != hj {
+			return hi > hj
+		}
+		return names[i] < names[j]
+	})
+
+	manifest := AtlasManifest{Regions: map[string]Region{}}
+	var x, y, shelf int
+	for _, name := range names {
+		size := images[name].Bounds().Size()
+		w, h := size.X+2*padding, size.Y+2*padding
+		if w > maxWidth {
+			return nil, manifest, fmt.Errorf("%v is %dpx wide, atlases are at most %dpx", name, size.X, maxWidth)
+		}
+		if x+w > maxWidth {
+			x, y, shelf = 0, y+shelf, 0
+		}
+		manifest.Regions[name] = Region{X: x + padding, Y: y + padding, W: size.X, H: size.Y}
+		x += w
+		if h > shelf {
+			shelf = h
+		}
+		if x > manifest.Width {
+			manifest.Width = x
+		}
+	}
+	manifest.Height = y + shelf
+	if manifest.Height > maxHeight {
+		return nil, manifest, fmt.Errorf("the images need an atlas %dpx tall, atlases are at most %dpx", manifest.Height, maxHeight)
+	}
+
+	atlas := image.NewRGBA(image.Rect(0, 0, manifest.Width, manifest.Height))
+	for name, r := range manifest.Regions {
+		img := images[name]
+		draw.Draw(atlas, image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H), img, img.Bounds().Min, draw.Src)
+		extrude(atlas, r, padding)
+	}
+	return atlas, manifest, nil
+}
+
+// extrude copies the region's edge pixels out into its padding.
+func extrude(atlas *image.RGBA, r Region, padding int) {
+	for p := 1; p <= padding; p++ {
+		draw.Draw(atlas, image.Rect(r.X-p, r.Y, r.X-p+1, r.Y+r.H), atlas, image.Pt(r.X, r.Y), draw.Src)
+		draw.Draw(atlas, image.Rect(r.X+r.W+p-1, r.Y, r.X+r.W+p, r.Y+r.H), atlas, image.Pt(r.X+r.W-1, r.Y), draw.Src)
+	}
+	// rows last, so they take the corners along with them
+	for p := 1; p <= padding; p++ {
+		draw.Draw(atlas, image.Rect(r.X-padding, r.Y-p, r.X+r.W+padding, r.Y-p+1), atlas, image.Pt(r.X-padding, r.Y), draw.Src)
+		draw.Draw(atlas, image.Rect(r.X-padding, r.Y+r.H+p-1, r.X+r.W+padding, r.Y+r.H+p), atlas, image.Pt(r.X-padding, r.Y+r.H-1), draw.Src)
+	}
+}
+
+// WriteAtlas saves a packed atlas as name.png and its manifest as name.json.
+func WriteAtlas(name string, img image.Image, manifest AtlasManifest) error {
+	manifest.Image = filepath.Base(name) + ".png"
+	f, err := os.Create(name + ".png")
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name+".json", data, 0644)
+}
@@ -0,0 +1,82 @@
+package eng
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestPackAtlas(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	images := map[string]image.Image{
+		"tall":  solid(10, 20, red),
+		"b":     solid(10, 10, color.RGBA{0, 255, 0, 255}),
+		"c":     solid(10, 10, color.RGBA{0, 0, 255, 255}),
+		"small": solid(4, 4, color.RGBA{255, 255, 0, 255}),
+	}
+	// each 10px image takes 12px with its padding, so two fit on a shelf
+	img, manifest, err := PackAtlas(images, 30, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Region{
+		// tallest first, then by name
+		"tall": {X: 1, Y: 1, W: 10, H: 20},
+		"b":    {X: 13, Y: 1, W: 10, H: 10},
+		// the first shelf is full, so the next starts under the tallest image
+		"c":     {X: 1, Y: 23, W: 10, H: 10},
+		"small": {X: 13, Y: 23, W: 4, H: 4},
+	}
+	for name, r := range want {
+		if got := manifest.Regions[name]; got != r {
+			t.Errorf("%v is at %+v, want %+v", name, got, r)
+		}
+	}
+	if manifest.Width != 24 || manifest.Height != 34 {
+		t.Errorf("atlas is %dx%d, want 24x34", manifest.Width, manifest.Height)
+	}
+	if b := img.Bounds(); b.Dx() != manifest.Width || b.Dy() != manifest.Height {
+		t.Errorf("atlas image is %v, manifest says %dx%d", b, manifest.Width, manifest.Height)
+	}
+
+	r := manifest.Regions["tall"]
+	for _, p := range []image.Point{{r.X, r.Y}, {r.X + r.W - 1, r.Y + r.H - 1}, {r.X - 1, r.Y - 1}, {r.X + r.W, r.Y + r.H}} {
+		if got := img.RGBAAt(p.X, p.Y); got != red {
+			t.Errorf("pixel %v is %v, want the image or its padding in %v", p, got, red)
+		}
+	}
+
+	atlas := &Atlas{AtlasManifest: manifest}
+	if uv, ok := atlas.UV("c"); !ok || uv != (mgl32.Vec4{1.0 / 24, 23.0 / 34, 11.0 / 24, 33.0 / 34}) {
+		t.Errorf("c has UV %v", uv)
+	}
+	if _, ok := atlas.UV("missing"); ok {
+		t.Error("found a UV for an image that isn't in the atlas")
+	}
+}
+
+func TestPackAtlasTooBig(t *testing.T) {
+	if _, _, err := PackAtlas(map[string]image.Image{"wide": solid(40, 1, color.White)}, 32, 32, 0); err == nil {
+		t.Error("packed an image wider than the atlas")
+	}
+	// 1px padding makes each 15px image 17px, so they don't share shelves
+	images := map[string]image.Image{
+		"a": solid(15, 15, color.White),
+		"b": solid(15, 15, color.White),
+	}
+	if _, _, err := PackAtlas(images, 32, 32, 1); err == nil {
+		t.Error("packed images taller than the atlas")
+	}
+	if _, _, err := PackAtlas(images, 32, 34, 1); err != nil {
+		t.Error(err)
+	}
+}
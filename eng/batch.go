@@ -0,0 +1,152 @@
+package eng
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// floats per vertex: position, texture coordinates, color
+const batchVertexSize = 2 + 2 + 3
+
+// the unit quad every sprite is made from, as two triangles
+var batchQuad = [6]mgl32.Vec2{{0, 1}, {1, 0}, {0, 0}, {0, 1}, {1, 1}, {1, 0}}
+
+// the texture coordinates of a whole texture: left, top, right and bottom
+var wholeTexture = mgl32.Vec4{0, 0, 1, 1}
+
+type batchSprite struct {
+	texture        *Texture2D
+	uv             mgl32.Vec4
+	position, size mgl32.Vec2
+	rotate         float64
+	color          mgl32.Vec3
+}
+
+// SpriteBatch draws textured sprites, queueing them up and drawing every
+// sprite sharing a texture with a single draw call. Draw queues a sprite and
+// Flush draws everything queued since the last Flush.
+//
+// Sprites are sorted by texture, so sprites drawn between two flushes have no
+// order: flush between things that have to be drawn on top of each other.
+type SpriteBatch struct {
+	shader   *Shader
+	vao, vbo uint32
+
+	sprites  []batchSprite
+	vertices []float32
+}
+
+func NewSpriteBatch(shader *Shader) *SpriteBatch {
+	var vao, vbo uint32
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+
+	SetAttribute(shader.ID, "vertex", 4, gl.FLOAT, batchVertexSize*4, 0)
+	SetAttribute(shader.ID, "color", 3, gl.FLOAT, batchVertexSize*4, 4*4)
+
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+
+	return &SpriteBatch{
+		shader: shader,
+		vao:    vao,
+		vbo:    vbo,
+	}
+}
+
+// Draw queues a sprite centred on position, turned by rotate radians and
+// tinted by color.
+func (s *SpriteBatch) Draw(texture *Texture2D, position, size mgl32.Vec2, rotate float64, color mgl32.Vec3) {
+	s.draw(texture, wholeTexture, position, size, rotate, color)
+}
+
+// DrawRegion queues the atlas's image called name like Draw. It reports
+// whether the atlas has the image.
+func (s *SpriteBatch) DrawRegion(atlas *Atlas, name string, position, size mgl32.Vec2, rotate float64, color mgl32.Vec3) bool {
+	if atlas == nil {
+		return false
+	}
+	uv, ok := atlas.UV(name)
+	if !ok {
+		return false
+	}
+	s.draw(atlas.Texture, uv, position, size, rotate, color)
+	return true
+}
+
+func (s *SpriteBatch) draw(texture *Texture2D, uv mgl32.Vec4, position, size mgl32.Vec2, rotate float64, color mgl32.Vec3) {
+	s.sprites = append(s.sprites, batchSprite{
+		texture:  texture,
+		uv:       uv,
+		position: position,
+		size:     size,
+		rotate:   rotate,
+		color:    color,
+	})
+}
+
+// Flush draws the queued sprites, one draw call per texture.
+func (s *SpriteBatch) Flush() {
+	if len(s.sprites) == 0 {
+		return
+	}
+	// stable, so sprites with the same texture keep the order they were drawn in
+	sort.SliceStable(s.sprites, func(i, j int) bool {
+		return s.sprites[i].texture.ID < s.sprites[j].texture.ID
+	})
+
+	s.vertices = s.vertices[:0]
+	for _, sprite := range s.sprites {
+		s.vertices = sprite.appendVertices(s.vertices)
+	}
+
+	s.shader.Use()
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindVertexArray(s.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, s.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(s.vertices)*4, gl.Ptr(s.vertices), gl.STREAM_DRAW)
+
+	start := 0
+	for i := 1; i <= len(s.sprites); i++ {
+		if i < len(s.sprites) && s.sprites[i].texture == s.sprites[start].texture {
+			continue
+		}
+		s.sprites[start].texture.Bind()
+		gl.DrawArrays(gl.TRIANGLES, int32(start*len(batchQuad)), int32((i-start)*len(batchQuad)))
+		start = i
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+	CheckGLErrors()
+
+	s.sprites = s.sprites[:0]
+}
+
+// appendVertices does on the CPU what a model matrix would do on the GPU:
+// scale the unit quad, centre it, rotate it and move it into place.
+func (b batchSprite) appendVertices(vertices []float32) []float32 {
+	sin, cos := math.Sincos(b.rotate)
+	s, c := float32(sin), float32(cos)
+	for _, corner := range batchQuad {
+		x := (corner.X() - 0.5) * b.size.X()
+		y := (corner.Y() - 0.5) * b.size.Y()
+		vertices = append(vertices,
+			b.position.X()+x*c-y*s, b.position.Y()+x*s+y*c,
+			b.uv[0]+corner.X()*(b.uv[2]-b.uv[0]), b.uv[1]+corner.Y()*(b.uv[3]-b.uv[1]),
+			b.color.X(), b.color.Y(), b.color.Z(),
+		)
+	}
+	return vertices
+}
+
+func (s *SpriteBatch) Destroy() {
+	gl.DeleteVertexArrays(1, &s.vao)
+	gl.DeleteBuffers(1, &s.vbo)
+}
@@ -0,0 +1,116 @@
+package eng
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/jakecoffman/cp/v2"
+)
+
+// Camera is a 2D view onto the world. The world is y-down, like the screen.
+type Camera struct {
+	// Position is the point of the world at the centre of the screen.
+	Position cp.Vector
+	// Zoom 1 shows ViewWidth by ViewHeight world units, 2 shows half as much.
+	Zoom float64
+
+	ViewWidth, ViewHeight float64
+
+	// Bounds is the world. The camera never shows past its edges unless the
+	// whole world fits on screen, in which case it is centred.
+	Bounds cp.BB
+
+	MinZoom, MaxZoom float64
+	// FollowSpeed is how quickly Follow catches up, higher is snappier.
+	FollowSpeed float64
+	// Margin is the room Follow leaves around what it follows.
+	Margin float64
+}
+
+func NewCamera(viewWidth, viewHeight float64) *Camera {
+	return &Camera{
+		Position:    cp.Vector{viewWidth / 2, viewHeight / 2},
+		Zoom:        1,
+		ViewWidth:   viewWidth,
+		ViewHeight:  viewHeight,
+		Bounds:      cp.NewBB(0, 0, viewWidth, viewHeight),
+		MinZoom:     0.25,
+		MaxZoom:     1,
+		FollowSpeed: 4,
+		Margin:      200,
+	}
+}
+
+// Follow eases the camera towards framing bb.
+func (c *Camera) Follow(bb cp.BB, dt float64) {
+	pos, zoom := c.frame(bb)
+	t := 1 - math.Exp(-c.FollowSpeed*dt)
+	c.Zoom += (zoom - c.Zoom) * t
+	c.Position = c.Position.Lerp(pos, t)
+	c.Position = c.clamp(c.Position, c.Zoom)
+}
+
+// LookAt frames bb straight away.
+func (c *Camera) LookAt(bb cp.BB) {
+	c.Position, c.Zoom = c.frame(bb)
+}
+
+// frame returns the position and zoom that fit bb plus the margin on screen.
+func (c *Camera) frame(bb cp.BB) (cp.Vector, float64) {
+	w := bb.R - bb.L + 2*c.Margin
+	h := bb.T - bb.B + 2*c.Margin
+	zoom := math.Min(c.ViewWidth/w, c.ViewHeight/h)
+
+	// no point zooming out further than the whole world
+	fitWorld := math.Min(c.ViewWidth/(c.Bounds.R-c.Bounds.L), c.ViewHeight/(c.Bounds.T-c.Bounds.B))
+	zoom = cp.Clamp(zoom, math.Min(math.Max(c.MinZoom, fitWorld), c.MaxZoom), c.MaxZoom)
+
+	return c.clamp(bb.Center(), zoom), zoom
+}
+
+// clamp keeps the view at pos inside the bounds.
+func (c *Camera) clamp(pos cp.Vector, zoom float64) cp.Vector {
+	halfW, halfH := c.ViewWidth/zoom/2, c.ViewHeight/zoom/2
+	center := c.Bounds.Center()
+	if 2*halfW >= c.Bounds.R-c.Bounds.L {
+		pos.X = center.X
+	} else {
+		pos.X = cp.Clamp(pos.X, c.Bounds.L+halfW, c.Bounds.R-halfW)
+	}
+	if 2*halfH >= c.Bounds.T-c.Bounds.B {
+		pos.Y = center.Y
+	} else {
+		pos.Y = cp.Clamp(pos.Y, c.Bounds.B+halfH, c.Bounds.T-halfH)
+	}
+	return pos
+}
+
+// View is the part of the world on screen.
+func (c *Camera) View() cp.BB {
+	halfW, halfH := c.ViewWidth/c.Zoom/2, c.ViewHeight/c.Zoom/2
+	return cp.NewBB(c.Position.X-halfW, c.Position.Y-halfH, c.Position.X+halfW, c.Position.Y+halfH)
+}
+
+// ViewProjection maps the view to clip space, for the projection uniform of
+// the shaders that draw the world.
+func (c *Camera) ViewProjection() mgl32.Mat4 {
+	view := c.View()
+	// y-down: the top of the screen is the smaller y
+	return mgl32.Ortho(float32(view.L), float32(view.R), float32(view.T), float32(view.B), -1, 1)
+}
+
+// ScreenToWorld converts window coordinates, from the top left of a window
+// ww by wh big, to the world.
+func (c *Camera) ScreenToWorld(x, y float64, ww, wh int) cp.Vector {
+	view := c.View()
+	return cp.Vector{
+		X: view.L + x/float64(ww)*(view.R-view.L),
+		Y: view.B + y/float64(wh)*(view.T-view.B),
+	}
+}
+
+// WorldToScreen is the inverse of ScreenToWorld.
+func (c *Camera) WorldToScreen(v cp.Vector, ww, wh int) (float64, float64) {
+	view := c.View()
+	return (v.X - view.L) / (view.R - view.L) * float64(ww), (v.Y - view.B) / (view.T - view.B) * float64(wh)
+}
@@ -57,6 +57,8 @@ const (
 var (
 	DefaultOutline = FColor{0, .2, .2, 1}
 	DefaultFill    = FColor{.8, .8, .8, 1}
+	// ConstraintColor is what DrawSpace draws joints and springs in.
+	ConstraintColor = FColor{0, .75, 0, 1}
 )
 
 type FColor struct {
@@ -67,10 +69,59 @@ func (cpr *CPRenderer) DrawSpace(space *cp.Space) {
 	space.EachShape(func(obj *cp.Shape) {
 		cpr.DrawShape(obj, DefaultOutline, DefaultFill)
 	})
-	// TODO
-	//space.EachConstraint(func(constraint *cp.Constraint) {
-	//	cp.DrawConstraint(constraint, nil)
-	//})
+	space.EachConstraint(func(constraint *cp.Constraint) {
+		cp.DrawConstraint(constraint, cpDrawer{cpr})
+	})
+}
+
+// cpDrawer lets Chipmunk's own drawing code draw with a CPRenderer. Only
+// DrawConstraint uses it, since the constraints' bodies aren't exported.
+type cpDrawer struct {
+	*CPRenderer
+}
+
+func (d cpDrawer) DrawCircle(pos cp.Vector, angle, radius float64, outline, fill cp.FColor, data interface{}) {
+	d.CPRenderer.DrawCircle(pos, angle, radius, FColor(outline), FColor(fill))
+}
+
+func (d cpDrawer) DrawSegment(a, b cp.Vector, fill cp.FColor, data interface{}) {
+	d.CPRenderer.DrawSegment(a, b, FColor(fill))
+}
+
+func (d cpDrawer) DrawFatSegment(a, b cp.Vector, radius float64, outline, fill cp.FColor, data interface{}) {
+	d.CPRenderer.DrawFatSegment(a, b, radius, FColor(outline), FColor(fill))
+}
+
+func (d cpDrawer) DrawPolygon(count int, verts []cp.Vector, radius float64, outline, fill cp.FColor, data interface{}) {
+	d.CPRenderer.DrawPolygon(count, verts, radius, FColor(outline), FColor(fill))
+}
+
+func (d cpDrawer) DrawDot(size float64, pos cp.Vector, fill cp.FColor, data interface{}) {
+	d.CPRenderer.DrawDot(size, pos, FColor(fill))
+}
+
+func (d cpDrawer) Flags() uint {
+	return cp.DRAW_SHAPES | cp.DRAW_CONSTRAINTS
+}
+
+func (d cpDrawer) OutlineColor() cp.FColor {
+	return cp.FColor(DefaultOutline)
+}
+
+func (d cpDrawer) ShapeColor(shape *cp.Shape, data interface{}) cp.FColor {
+	return cp.FColor(DefaultFill)
+}
+
+func (d cpDrawer) ConstraintColor() cp.FColor {
+	return cp.FColor(ConstraintColor)
+}
+
+func (d cpDrawer) CollisionPointColor() cp.FColor {
+	return cp.FColor{1, 0, 0, 1}
+}
+
+func (d cpDrawer) Data() interface{} {
+	return nil
 }
 
 func (cpr *CPRenderer) DrawShape(shape *cp.Shape, outline, fill FColor) {
@@ -0,0 +1,174 @@
+package eng
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/jakecoffman/cp/v2"
+)
+
+// BlendMode is how particles mix with what's behind them.
+type BlendMode int
+
+const (
+	// BlendAlpha covers what's behind, for smoke and dust.
+	BlendAlpha BlendMode = iota
+	// BlendAdditive brightens what's behind, for sparks and glows.
+	BlendAdditive
+)
+
+var BlendModes = []BlendMode{BlendAlpha, BlendAdditive}
+
+var blendModeNames = []string{"alpha", "additive"}
+
+func (b BlendMode) String() string {
+	if b < 0 || int(b) >= len(blendModeNames) {
+		return fmt.Sprintf("BlendMode(%d)", int(b))
+	}
+	return blendModeNames[b]
+}
+
+func (b BlendMode) MarshalText() ([]byte, error) {
+	if b < 0 || int(b) >= len(blendModeNames) {
+		return nil, fmt.Errorf("unknown blend mode %d", int(b))
+	}
+	return []byte(blendModeNames[b]), nil
+}
+
+func (b *BlendMode) UnmarshalText(text []byte) error {
+	for i, name := range blendModeNames {
+		if name == string(text) {
+			*b = BlendMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown blend mode %q", text)
+}
+
+func (b BlendMode) apply() {
+	if b == BlendAdditive {
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
+	} else {
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	}
+}
+
+// Emitter describes the particles something gives off. Particles keep a
+// pointer to their emitter, so changes show up on particles already flying.
+type Emitter struct {
+	// Texture names the texture particles are drawn with.
+	Texture string
+	Blend   BlendMode
+
+	// Rate is particles per second while attached to an object.
+	Rate float32
+	// Burst is how many particles Emit gives off at once.
+	Burst int
+
+	LifeMin, LifeMax float32
+	// Particles leave at between SpeedMin and SpeedMax, up to Spread degrees
+	// either side of Direction. 0 degrees is right and 90 is down.
+	SpeedMin, SpeedMax float32
+	Direction, Spread  float32
+	Gravity            mgl32.Vec2
+
+	// Colors and Sizes are spread evenly over a particle's life and blended
+	// between, the first at birth and the last at death.
+	Colors []mgl32.Vec4
+	Sizes  []float32
+	// ColorJitter darkens each particle by up to this much.
+	ColorJitter float32
+}
+
+// color is the particle's color t of the way through its life.
+func (e *Emitter) color(t float32) mgl32.Vec4 {
+	if len(e.Colors) == 0 {
+		return mgl32.Vec4{1, 1, 1, 1}
+	}
+	i, f := stop(len(e.Colors), t)
+	if i+1 >= len(e.Colors) {
+		return e.Colors[i]
+	}
+	return e.Colors[i].Add(e.Colors[i+1].Sub(e.Colors[i]).Mul(f))
+}
+
+// size is the particle's size t of the way through its life.
+func (e *Emitter) size(t float32) float32 {
+	if len(e.Sizes) == 0 {
+		return 10
+	}
+	i, f := stop(len(e.Sizes), t)
+	if i+1 >= len(e.Sizes) {
+		return e.Sizes[i]
+	}
+	return e.Sizes[i] + (e.Sizes[i+1]-e.Sizes[i])*f
+}
+
+// stop finds which of n evenly spaced stops t is past, and how far towards
+// the next one it is.
+func stop(n int, t float32) (int, float32) {
+	if n == 1 || t <= 0 {
+		return 0, 0
+	}
+	if t >= 1 {
+		return n - 1, 0
+	}
+	pos := t * float32(n-1)
+	i := int(pos)
+	return i, pos - float32(i)
+}
+
+// LoadEmitters reads a file of named emitters.
+func LoadEmitters(filename string) (map[string]*Emitter, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var emitters map[string]*Emitter
+	if err = json.Unmarshal(data, &emitters); err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	return emitters, nil
+}
+
+func SaveEmitters(filename string, emitters map[string]*Emitter) error {
+	data, err := json.MarshalIndent(emitters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Attachment is an emitter following an object around.
+type Attachment struct {
+	Emitter *Emitter
+	// Offset is where on the object particles come from, turning with it.
+	Offset cp.Vector
+
+	// due is the particles owed from earlier ticks, since Rate rarely comes
+	// to a whole number per tick
+	due float32
+}
+
+// Attach makes the object give off e's particles at offset, if it isn't
+// already.
+func (p *Object) Attach(e *Emitter, offset cp.Vector) {
+	for _, a := range p.Attachments {
+		if a.Emitter == e {
+			return
+		}
+	}
+	p.Attachments = append(p.Attachments, &Attachment{Emitter: e, Offset: offset})
+}
+
+func (p *Object) Detach(e *Emitter) {
+	for i, a := range p.Attachments {
+		if a.Emitter == e {
+			p.Attachments = append(p.Attachments[:i], p.Attachments[i+1:]...)
+			return
+		}
+	}
+}
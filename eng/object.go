@@ -18,6 +18,9 @@ type Object struct {
 	*cp.Body
 	*cp.Shape
 
+	// Attachments are the emitters the object gives off particles from.
+	Attachments []*Attachment
+
 	// lastPosition/lastAngle capture the state at the start of each physics
 	// sub-step (before cp.Space.Step). This is correct because Object.Update
 	// is called once per sub-step, immediately before the step runs.
@@ -1,6 +1,7 @@
 package eng
 
 import (
+	"math"
 	"math/rand"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
@@ -9,30 +10,45 @@ import (
 
 type Particle struct {
 	Position, Velocity mgl32.Vec2
-	Color              mgl32.Vec4
-	Life               float64
+	// Shade darkens the emitter's colors for this particle.
+	Shade float32
+	// Life counts down from MaxLife.
+	Life, MaxLife float64
+	Emitter       *Emitter
 }
 
 func NewParticle() *Particle {
-	return &Particle{
-		Color: mgl32.Vec4{1, 1, 1, 1},
-	}
+	return &Particle{}
 }
 
+// floats per particle instance: offset, size, color
+const particleInstanceSize = 2 + 1 + 4
+
 type ParticleGenerator struct {
 	particles []*Particle
 	freeList  []int // indices of dead particles (stack)
 	Amount    int
 	Shader    *Shader
+	// Texture is drawn for emitters whose texture isn't in Resources.
 	Texture   *Texture2D
+	Resources *ResourceManager
 	VAO       uint32
+
+	// instanceVBO holds one emitter's live particles at a time while drawing
+	instanceVBO uint32
+	// instances are the live particles' instance data, by emitter in the
+	// order emitters are first seen
+	instances map[*Emitter][]float32
+	emitters  []*Emitter
 }
 
-func NewParticleGenerator(shader *Shader, texture *Texture2D, amount int) *ParticleGenerator {
+func NewParticleGenerator(shader *Shader, resources *ResourceManager, texture *Texture2D, amount int) *ParticleGenerator {
 	particleGenerator := &ParticleGenerator{
-		Shader:  shader,
-		Texture: texture,
-		Amount:  amount,
+		Shader:    shader,
+		Texture:   texture,
+		Resources: resources,
+		Amount:    amount,
+		instances: map[*Emitter][]float32{},
 	}
 
 	var VBO uint32
@@ -54,7 +70,21 @@ func NewParticleGenerator(shader *Shader, texture *Texture2D, amount int) *Parti
 
 	gl.EnableVertexAttribArray(0)
 	gl.VertexAttribPointer(0, 4, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+
+	// one offset, size and color per particle, shared by the quad's six vertices
+	gl.GenBuffers(1, &particleGenerator.instanceVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, particleGenerator.instanceVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, amount*particleInstanceSize*4, nil, gl.STREAM_DRAW)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(1, 3, gl.FLOAT, false, particleInstanceSize*4, gl.PtrOffset(0))
+	gl.VertexAttribDivisor(1, 1)
+	gl.EnableVertexAttribArray(2)
+	gl.VertexAttribPointer(2, 4, gl.FLOAT, false, particleInstanceSize*4, gl.PtrOffset(3*4))
+	gl.VertexAttribDivisor(2, 1)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
+	CheckGLErrors()
 
 	particleGenerator.freeList = make([]int, 0, amount)
 	for i := 0; i < amount; i++ {
@@ -65,11 +95,47 @@ func NewParticleGenerator(shader *Shader, texture *Texture2D, amount int) *Parti
 	return particleGenerator
 }
 
-func (p *ParticleGenerator) Update(dt float64, position, velocity mgl32.Vec2, newParticles int, offset mgl32.Vec2) {
-	for i := 0; i < newParticles; i++ {
-		unusedParticles := p.firstUnusedParticle()
-		p.respawnParticle(p.particles[unusedParticles], position, velocity, offset)
+// Emit bursts e's particles out of position.
+func (p *ParticleGenerator) Emit(e *Emitter, position mgl32.Vec2) {
+	p.EmitN(e, e.Burst, position)
+}
+
+// EmitFrom gives off the particles due from o's attachments over dt.
+func (p *ParticleGenerator) EmitFrom(o *Object, dt float64) {
+	for _, a := range o.Attachments {
+		a.due += a.Emitter.Rate * float32(dt)
+		n := int(a.due)
+		if n == 0 {
+			continue
+		}
+		a.due -= float32(n)
+		p.EmitN(a.Emitter, n, V(o.LocalToWorld(a.Offset)))
 	}
+}
+
+// EmitN gives off n of e's particles at position.
+func (p *ParticleGenerator) EmitN(e *Emitter, n int, position mgl32.Vec2) {
+	for i := 0; i < n; i++ {
+		life := e.LifeMin + rand.Float32()*(e.LifeMax-e.LifeMin)
+		if life <= 0 {
+			continue
+		}
+		particle := p.particles[p.firstUnusedParticle()]
+
+		angle := mgl32.DegToRad(e.Direction + (rand.Float32()*2-1)*e.Spread)
+		speed := e.SpeedMin + rand.Float32()*(e.SpeedMax-e.SpeedMin)
+
+		particle.Position = position
+		particle.Velocity = mgl32.Vec2{float32(math.Cos(float64(angle))), float32(math.Sin(float64(angle)))}.Mul(speed)
+		particle.Shade = 1 - rand.Float32()*e.ColorJitter
+		particle.Life = float64(life)
+		particle.MaxLife = float64(life)
+		particle.Emitter = e
+	}
+}
+
+// Update moves the live particles along and lets them age.
+func (p *ParticleGenerator) Update(dt float64) {
 	for i := 0; i < p.Amount; i++ {
 		particle := p.particles[i]
 		if particle.Life <= 0 {
@@ -81,26 +147,60 @@ func (p *ParticleGenerator) Update(dt float64, position, velocity mgl32.Vec2, ne
 			p.freeList = append(p.freeList, i)
 			continue
 		}
+		particle.Velocity = particle.Velocity.Add(particle.Emitter.Gravity.Mul(float32(dt)))
 		particle.Position = particle.Position.Add(particle.Velocity.Mul(float32(dt)))
-		particle.Color = particle.Color.Sub(mgl32.Vec4{0, 0, 0, float32(dt) * 2.5})
 	}
 }
 
+// Draw draws the live particles with one instanced draw call per emitter.
 func (p *ParticleGenerator) Draw() {
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
-	p.Shader.Use()
-	// TODO: convert to instanced rendering for better GPU performance.
+	for e := range p.instances {
+		p.instances[e] = p.instances[e][:0]
+	}
+	p.emitters = p.emitters[:0]
 	for _, particle := range p.particles {
-		if particle.Life > 0 {
-			p.Shader.SetVec2f("offset", particle.Position)
-			p.Shader.SetVec4f("color", particle.Color)
-			p.Texture.Bind()
-			gl.BindVertexArray(p.VAO)
-			gl.DrawArrays(gl.TRIANGLES, 0, 6)
-			gl.BindVertexArray(0)
+		if particle.Life <= 0 {
+			continue
+		}
+		e := particle.Emitter
+		t := float32(1 - particle.Life/particle.MaxLife)
+		color := e.color(t)
+		instances, seen := p.instances[e]
+		if !seen || len(instances) == 0 {
+			p.emitters = append(p.emitters, e)
+		}
+		p.instances[e] = append(instances,
+			particle.Position.X(), particle.Position.Y(), e.size(t),
+			color.X()*particle.Shade, color.Y()*particle.Shade, color.Z()*particle.Shade, color.W(),
+		)
+	}
+	if len(p.emitters) == 0 {
+		return
+	}
+
+	p.Shader.Use()
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindVertexArray(p.VAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, p.instanceVBO)
+	for _, e := range p.emitters {
+		instances := p.instances[e]
+		e.Blend.apply()
+		p.texture(e).Bind()
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(instances)*4, gl.Ptr(instances))
+		gl.DrawArraysInstanced(gl.TRIANGLES, 0, 6, int32(len(instances)/particleInstanceSize))
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+	BlendAlpha.apply()
+}
+
+func (p *ParticleGenerator) texture(e *Emitter) *Texture2D {
+	if p.Resources != nil {
+		if texture, ok := p.Resources.textures[e.Texture]; ok {
+			return texture
 		}
 	}
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	return p.Texture
 }
 
 // firstUnusedParticle returns the index of a dead particle using a free-list.
@@ -118,12 +218,3 @@ func (p *ParticleGenerator) firstUnusedParticle() int {
 	p.particles[0].Life = 0
 	return 0
 }
-
-func (p *ParticleGenerator) respawnParticle(particle *Particle, position, velocity mgl32.Vec2, offset mgl32.Vec2) {
-	random := (float32(rand.Intn(100)) - 50.0) / 10.0
-	rColor := 0.5 + (float32(rand.Intn(100)) / 100.0)
-	particle.Position = position.Add(mgl32.Vec2{offset.X() + random, offset.Y() + random})
-	particle.Color = mgl32.Vec4{rColor, rColor, rColor, 1}
-	particle.Life = 1
-	particle.Velocity = velocity.Mul(0.1)
-}
@@ -3,6 +3,7 @@ package eng
 import (
 	"io/ioutil"
 	"os"
+	"sort"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
 )
@@ -68,6 +69,22 @@ func (r *ResourceManager) Texture(name string) *Texture2D {
 	return t
 }
 
+// HasTexture reports whether a texture called name is loaded.
+func (r *ResourceManager) HasTexture(name string) bool {
+	_, ok := r.textures[name]
+	return ok
+}
+
+// TextureNames lists the loaded textures in alphabetical order.
+func (r *ResourceManager) TextureNames() []string {
+	var names []string
+	for name := range r.textures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (r *ResourceManager) Clear() {
 	for _, shader := range r.shaders {
 		gl.DeleteProgram(shader.ID)
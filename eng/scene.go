@@ -5,7 +5,7 @@ import (
 	"runtime"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
-	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/glfw/v3.3/glfw"
 )
 
 // PhysicsDt is the fixed physics timestep (seconds per sub-step).
@@ -18,6 +18,19 @@ type Scene interface {
 	Close()
 }
 
+// Simulation is the part of a Scene that can be stepped without a window.
+type Simulation interface {
+	Update(float64)
+}
+
+// RunHeadless steps sim at PhysicsDt for the given number of ticks. No window
+// or GL context is created, so it works on machines without a display.
+func RunHeadless(sim Simulation, ticks int) {
+	for i := 0; i < ticks; i++ {
+		sim.Update(PhysicsDt)
+	}
+}
+
 func Run(scene Scene) {
 	runtime.LockOSThread()
 
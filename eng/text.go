@@ -1,34 +1,65 @@
 package eng
 
 import (
-	"fmt"
 	"image"
 	"image/draw"
 	"io/ioutil"
+	"log"
 	"os"
+	"strings"
+	"unicode"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
-	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
 )
 
+const (
+	// glyph atlases start this big and double until they're glyphAtlasMax
+	glyphAtlasSize = 512
+	glyphAtlasMax  = 4096
+	// pixels between glyphs, so filtering doesn't pick up the neighbours
+	glyphPadding = 1
+)
+
+// Align is where text sits relative to the x it's printed at.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// TextRenderer draws text from a glyph atlas: one texture with every glyph
+// drawn so far, rasterised the first time it's used.
 type TextRenderer struct {
 	*Shader
 	vao, vbo uint32
-	fontChar []character
-	texture  uint32 // Holds the glyph texture id.
+
+	face       font.Face
+	ttf        *truetype.Font
+	lineHeight float32
+	glyphs     map[rune]*glyph
+
+	// atlas is the glyphs on the CPU, uploaded to texture as they're added
+	atlas   *image.Alpha
+	texture uint32
+	// where the next glyph goes: x along the current shelf, which starts at
+	// shelfY and is shelfH tall
+	nextX, shelfY, shelfH int
+
+	vertices []float32
 }
 
-type character struct {
-	textureID uint32 // ID handle of the glyph texture
-	width     int    //glyph width
-	height    int    //glyph height
-	advance   int    //glyph advance
-	bearingH  int    //glyph bearing horizontal
-	bearingV  int    //glyph bearing vertical
+type glyph struct {
+	// where the glyph is in the atlas
+	x, y, w, h int
+	// offset from the pen position to the glyph's top left, y down
+	offX, offY float32
+	advance    float32
 }
 
 func NewTextRenderer(shader *Shader, width, height float32, font string, scale uint32) *TextRenderer {
@@ -38,7 +69,6 @@ func NewTextRenderer(shader *Shader, width, height float32, font string, scale u
 	gl.GenBuffers(1, &VBO)
 	gl.BindVertexArray(VAO)
 	gl.BindBuffer(gl.ARRAY_BUFFER, VBO)
-	gl.BufferData(gl.ARRAY_BUFFER, 6*4*4, nil, gl.DYNAMIC_DRAW)
 
 	gl.EnableVertexAttribArray(0)
 	gl.VertexAttribPointer(0, 4, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
@@ -55,6 +85,8 @@ func NewTextRenderer(shader *Shader, width, height float32, font string, scale u
 	return r
 }
 
+// Load switches to the font at fontPath, scale pixels high. Latin-1 is
+// rasterised up front and anything else the first time it's printed.
 func (t *TextRenderer) Load(fontPath string, scale uint32) error {
 	fd, err := os.Open(fontPath)
 	if err != nil {
@@ -62,152 +94,270 @@ func (t *TextRenderer) Load(fontPath string, scale uint32) error {
 	}
 	defer func() { _ = fd.Close() }()
 
-	low := rune(32)
-	high := rune(127)
-
 	data, err := ioutil.ReadAll(fd)
 	if err != nil {
 		return err
 	}
 
-	ttf, err := truetype.Parse(data)
+	t.ttf, err = truetype.Parse(data)
 	if err != nil {
 		return err
 	}
+	t.face = truetype.NewFace(t.ttf, &truetype.Options{
+		Size:    float64(scale),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	t.lineHeight = float32(t.face.Metrics().Height) / 64
+
+	t.glyphs = map[rune]*glyph{}
+	t.atlas = image.NewAlpha(image.Rect(0, 0, glyphAtlasSize, glyphAtlasSize))
+	t.nextX, t.shelfY, t.shelfH = 0, 0, 0
+	if t.texture == 0 {
+		gl.GenTextures(1, &t.texture)
+	}
+	t.upload()
 
-	t.fontChar = make([]character, 0, high-low+1)
 	t.SetColor(1.0, 1.0, 1.0, 1.0)
 
-	for ch := low; ch <= high; ch++ {
-		var char character
+	for ch := rune(32); ch <= unicode.MaxLatin1; ch++ {
+		if ch < 127 || ch >= 160 {
+			t.glyph(ch)
+		}
+	}
+	return nil
+}
 
-		ttfFace := truetype.NewFace(ttf, &truetype.Options{
-			Size:    float64(scale),
-			DPI:     72,
-			Hinting: font.HintingFull,
-		})
+// upload sends the whole atlas to the texture.
+func (t *TextRenderer) upload() {
+	size := t.atlas.Rect.Size()
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RED, int32(size.X), int32(size.Y), 0, gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(t.atlas.Pix))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
 
-		gBnd, gAdv, ok := ttfFace.GlyphBounds(ch)
-		if ok != true {
-			return fmt.Errorf("ttf face glyphBounds error")
-		}
+// glyph returns ch's glyph, adding it to the atlas the first time. Runes the
+// font doesn't have come out as a question mark.
+func (t *TextRenderer) glyph(ch rune) *glyph {
+	if g, ok := t.glyphs[ch]; ok {
+		return g
+	}
+	if ch != '?' && t.ttf.Index(ch) == 0 {
+		g := t.glyph('?')
+		t.glyphs[ch] = g
+		return g
+	}
 
-		gh := int32((gBnd.Max.Y - gBnd.Min.Y) >> 6)
-		gw := int32((gBnd.Max.X - gBnd.Min.X) >> 6)
+	g := &glyph{}
+	t.glyphs[ch] = g
+	dr, mask, maskp, advance, ok := t.face.Glyph(fixed.Point26_6{}, ch)
+	if !ok {
+		return g
+	}
+	g.advance = float32(advance) / 64
+	g.w, g.h = dr.Dx(), dr.Dy()
+	g.offX, g.offY = float32(dr.Min.X), float32(dr.Min.Y)
+	if g.w == 0 || g.h == 0 {
+		return g
+	}
 
-		if gw == 0 || gh == 0 {
-			gBnd = ttf.Bounds(fixed.Int26_6(scale))
-			gw = int32((gBnd.Max.X - gBnd.Min.X) >> 6)
-			gh = int32((gBnd.Max.Y - gBnd.Min.Y) >> 6)
+	if !t.place(g) {
+		log.Println("glyph atlas is full, can't draw", string(ch))
+		g.w, g.h = 0, 0
+		return g
+	}
+	draw.Draw(t.atlas, image.Rect(g.x, g.y, g.x+g.w, g.y+g.h), mask, maskp, draw.Src)
 
-			if gw == 0 || gh == 0 {
-				gw = 1
-				gh = 1
+	// upload just the new glyph
+	pix := make([]byte, 0, g.w*g.h)
+	for y := g.y; y < g.y+g.h; y++ {
+		start := t.atlas.PixOffset(g.x, y)
+		pix = append(pix, t.atlas.Pix[start:start+g.w]...)
+	}
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(g.x), int32(g.y), int32(g.w), int32(g.h), gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return g
+}
+
+// place finds room for g in the atlas, growing it when it's full.
+func (t *TextRenderer) place(g *glyph) bool {
+	for {
+		size := t.atlas.Rect.Size()
+		if t.nextX+g.w+glyphPadding > size.X {
+			t.nextX, t.shelfY, t.shelfH = 0, t.shelfY+t.shelfH, 0
+		}
+		if g.w+glyphPadding <= size.X && t.shelfY+g.h+glyphPadding <= size.Y {
+			g.x, g.y = t.nextX, t.shelfY
+			t.nextX += g.w + glyphPadding
+			if g.h+glyphPadding > t.shelfH {
+				t.shelfH = g.h + glyphPadding
 			}
+			return true
 		}
-
-		gAscent := int(-gBnd.Min.Y) >> 6
-		gdescent := int(gBnd.Max.Y) >> 6
-
-		char.width = int(gw)
-		char.height = int(gh)
-		char.advance = int(gAdv)
-		char.bearingV = gdescent
-		char.bearingH = int(gBnd.Min.X) >> 6
-
-		fg, bg := image.White, image.Black
-		rect := image.Rect(0, 0, int(gw), int(gh))
-		rgba := image.NewRGBA(rect)
-		draw.Draw(rgba, rgba.Bounds(), bg, image.Point{}, draw.Src)
-
-		c := freetype.NewContext()
-		c.SetDPI(72)
-		c.SetFont(ttf)
-		c.SetFontSize(float64(scale))
-		c.SetClip(rgba.Bounds())
-		c.SetDst(rgba)
-		c.SetSrc(fg)
-		c.SetHinting(font.HintingFull)
-
-		px := 0 - (int(gBnd.Min.X) >> 6)
-		py := gAscent
-		pt := freetype.Pt(px, py)
-
-		_, err = c.DrawString(string(ch), pt)
-		if err != nil {
-			return err
+		if size.Y >= glyphAtlasMax {
+			return false
 		}
-
-		var texture uint32
-		gl.GenTextures(1, &texture)
-		gl.BindTexture(gl.TEXTURE_2D, texture)
-		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(rgba.Rect.Dx()), int32(rgba.Rect.Dy()), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-
-		char.textureID = texture
-
-		t.fontChar = append(t.fontChar, char)
+		// twice as tall, keeping the glyphs already placed where they are
+		bigger := image.NewAlpha(image.Rect(0, 0, size.X, size.Y*2))
+		copy(bigger.Pix, t.atlas.Pix)
+		t.atlas = bigger
+		t.upload()
 	}
-	gl.BindTexture(gl.TEXTURE_2D, 0)
-	return nil
 }
 
-//SetColor allows you to set the text color to be used when you draw the text
+// SetColor allows you to set the text color to be used when you draw the text
 func (t *TextRenderer) SetColor(red float32, green float32, blue float32, alpha float32) {
 	t.Use().SetVec4f("textColor", mgl32.Vec4{red, green, blue, alpha})
 }
 
-//Printf draws a string to the screen, takes a list of arguments like printf
-func (t *TextRenderer) Print(text string, x64, y64 float64, scale float32) {
-	x, y := float32(x64), float32(y64)
-	indices := []rune(text)
-	if len(indices) == 0 {
-		return
-	}
-	t.Use()
-
-	lowChar := rune(32)
-
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindVertexArray(t.vao)
-
-	for i := range indices {
-		runeIndex := indices[i]
+// LineHeight is the distance between the baselines of two lines of text.
+func (t *TextRenderer) LineHeight(scale float32) float64 {
+	return float64(t.lineHeight * scale)
+}
 
-		if int(runeIndex)-int(lowChar) > len(t.fontChar) || runeIndex < lowChar {
-			continue
+// MeasureString returns how wide the widest line of text is and how tall
+// all of its lines are.
+func (t *TextRenderer) MeasureString(text string, scale float32) (float64, float64) {
+	lines := strings.Split(text, "\n")
+	var width float32
+	for _, line := range lines {
+		if w := t.lineWidth(line); w > width {
+			width = w
 		}
+	}
+	return float64(width * scale), float64(float32(len(lines)) * t.lineHeight * scale)
+}
 
-		ch := t.fontChar[runeIndex-lowChar]
-
-		xpos := x + float32(ch.bearingH)*scale
-		ypos := y - float32(ch.height-ch.bearingV)*scale
-		w := float32(ch.width) * scale
-		h := float32(ch.height) * scale
+func (t *TextRenderer) lineWidth(line string) float32 {
+	var width float32
+	prev := rune(-1)
+	for _, ch := range line {
+		if prev >= 0 {
+			width += float32(t.face.Kern(prev, ch)) / 64
+		}
+		width += t.glyph(ch).advance
+		prev = ch
+	}
+	return width
+}
 
-		var vertices = []float32{
-			xpos, ypos + h, 0.0, 1.0,
-			xpos + w, ypos, 1.0, 0.0,
-			xpos, ypos, 0.0, 0.0,
-			xpos, ypos + h, 0.0, 1.0,
-			xpos + w, ypos + h, 1.0, 1.0,
-			xpos + w, ypos, 1.0, 0.0,
+// Wrap breaks text into lines no wider than width, between words. Words
+// wider than width get a line to themselves.
+func (t *TextRenderer) Wrap(text string, width float64, scale float32) string {
+	var out strings.Builder
+	for i, paragraph := range strings.Split(text, "\n") {
+		if i > 0 {
+			out.WriteByte('\n')
 		}
+		var line string
+		for _, word := range strings.Fields(paragraph) {
+			if line == "" {
+				line = word
+				continue
+			}
+			if float64(t.lineWidth(line+" "+word)*scale) > width {
+				out.WriteString(line)
+				out.WriteByte('\n')
+				line = word
+			} else {
+				line += " " + word
+			}
+		}
+		out.WriteString(line)
+	}
+	return out.String()
+}
 
-		gl.BindTexture(gl.TEXTURE_2D, ch.textureID)
-		gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
-		gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(vertices)*4, gl.Ptr(vertices))
+// Print draws text with its first baseline at y, starting at x. Newlines
+// start new lines.
+func (t *TextRenderer) Print(text string, x, y float64, scale float32) {
+	t.PrintAligned(text, x, y, scale, AlignLeft)
+}
 
-		gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-		gl.DrawArrays(gl.TRIANGLES, 0, 6)
+// PrintAligned draws text like Print, with each line starting, centred on or
+// ending at x.
+func (t *TextRenderer) PrintAligned(text string, x, y float64, scale float32, align Align) {
+	// add any new glyphs first, in case the atlas grows and moves them all
+	for _, ch := range text {
+		t.glyph(ch)
+	}
 
-		x += float32(ch.advance>>6) * scale
+	t.vertices = t.vertices[:0]
+	baseline := float32(y)
+	for _, line := range strings.Split(text, "\n") {
+		pen := float32(x)
+		switch align {
+		case AlignCenter:
+			pen -= t.lineWidth(line) * scale / 2
+		case AlignRight:
+			pen -= t.lineWidth(line) * scale
+		}
+		t.appendLine(line, pen, baseline, scale)
+		baseline += t.lineHeight * scale
 	}
+	if len(t.vertices) == 0 {
+		return
+	}
+
+	t.Use()
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.BindVertexArray(t.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(t.vertices)*4, gl.Ptr(t.vertices), gl.STREAM_DRAW)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(t.vertices)/4))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 	gl.UseProgram(0)
-	return
+}
+
+// PrintWrapped draws text wrapped to width, aligned within it.
+func (t *TextRenderer) PrintWrapped(text string, x, y, width float64, scale float32, align Align) {
+	switch align {
+	case AlignCenter:
+		x += width / 2
+	case AlignRight:
+		x += width
+	}
+	t.PrintAligned(t.Wrap(text, width, scale), x, y, scale, align)
+}
+
+// appendLine adds a quad for each glyph of line to the vertices.
+func (t *TextRenderer) appendLine(line string, x, y, scale float32) {
+	size := t.atlas.Rect.Size()
+	aw, ah := float32(size.X), float32(size.Y)
+	prev := rune(-1)
+	for _, ch := range line {
+		if prev >= 0 {
+			x += float32(t.face.Kern(prev, ch)) / 64 * scale
+		}
+		prev = ch
+		g := t.glyph(ch)
+		if g.w > 0 && g.h > 0 {
+			xpos := x + g.offX*scale
+			ypos := y + g.offY*scale
+			w := float32(g.w) * scale
+			h := float32(g.h) * scale
+			u0, v0 := float32(g.x)/aw, float32(g.y)/ah
+			u1, v1 := float32(g.x+g.w)/aw, float32(g.y+g.h)/ah
+
+			t.vertices = append(t.vertices,
+				xpos, ypos+h, u0, v1,
+				xpos+w, ypos, u1, v0,
+				xpos, ypos, u0, v0,
+				xpos, ypos+h, u0, v1,
+				xpos+w, ypos+h, u1, v1,
+				xpos+w, ypos, u1, v0,
+			)
+		}
+		x += g.advance * scale
+	}
 }
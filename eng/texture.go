@@ -42,7 +42,11 @@ func (t *Texture2D) Generate(reader io.ReadCloser) {
 		log.Println("Error decoding image:", err)
 		return
 	}
+	t.GenerateFromImage(img)
+}
 
+// GenerateFromImage uploads an image that's already decoded.
+func (t *Texture2D) GenerateFromImage(img image.Image) {
 	rgba := image.NewRGBA(img.Bounds())
 	draw.Draw(rgba, rgba.Bounds(), img, image.Pt(0, 0), draw.Src)
 	size := rgba.Rect.Size()
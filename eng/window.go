@@ -1,6 +1,6 @@
 package eng
 
-import "github.com/go-gl/glfw/v3.2/glfw"
+import "github.com/go-gl/glfw/v3.3/glfw"
 
 type OpenGlWindow struct {
 	*glfw.Window
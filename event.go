@@ -0,0 +1,37 @@
+package fam
+
+import "github.com/jakecoffman/cp/v2"
+
+// EventKind is something that happened in the world worth showing off.
+type EventKind int
+
+const (
+	// EventEat is a player eating a fruit.
+	EventEat EventKind = iota
+	// EventBoom is a bomb going off.
+	EventBoom
+	// EventLand is a player landing from a fall.
+	EventLand
+	// EventBombed is a player caught in an explosion, shrinking back to
+	// their starting size.
+	EventBombed
+	// EventBump is something hitting a bumper, at the point it hit, or a
+	// spring pad launching.
+	EventBump
+	// EventJump is a player jumping off the ground.
+	EventJump
+)
+
+// Event is something that happened during a tick. The world only records
+// them, the game turns them into effects.
+type Event struct {
+	Kind EventKind
+	Pos  cp.Vector
+	// Player is who ate, landed, jumped or was bombed, nil for bombs going off,
+	// bumpers and spring pads.
+	Player *Player
+}
+
+func (w *World) emit(e Event) {
+	w.Events = append(w.Events, e)
+}
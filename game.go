@@ -1,18 +1,15 @@
 package fam
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"math"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
-	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/jakecoffman/cp/v2"
 	"github.com/jakecoffman/fam/eng"
@@ -43,54 +40,141 @@ const (
 	collisionBanana
 	collisionBomb
 	collisionWall
+	collisionBumper
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 type Game struct {
-	state      int
-	Keys       map[glfw.Key]bool
+	*World
+
+	// Seed seeds the world's random source. Zero picks one from the clock.
+	Seed int64
+	// ReplayFile, if set, is a recorded session to play back on start.
+	ReplayFile string
+
+	state int
+	// resumeState is what unpause goes back to
+	resumeState int
+
 	vsync      bool
 	fullscreen bool
 	window     *eng.OpenGlWindow
 	gui        *Gui
+	editor     *Editor
 
-	projection mgl32.Mat4
-
-	// mouse stuff
-	mouse      cp.Vector
-	mouseBody  *cp.Body
-	mouseJoint *cp.Constraint
+	Camera *eng.Camera
+	split  SplitScreen
+	// cursor is the mouse in window coordinates, to keep Mouse current as
+	// the camera moves
+	cursorX, cursorY float64
 
-	leftDown  *cp.Vector
 	rightDown *cp.Vector
 
-	drawingWallShape *Wall
-
-	Space *cp.Space
-
-	Players []*Player
-	Bananas []*Banana
-	Bombs   []*Bomb
-	Walls   []*Wall
+	// padDown holds the game-wide actions a gamepad held last tick, so they
+	// fire once per press
+	padDown map[InputAction]bool
 
 	*eng.ResourceManager
 
+	// Atlas has the textures packed together, so sprites share a draw call
+	Atlas *eng.Atlas
+	// textureFiles are the image files in textureDir, by name
+	textureFiles map[string]string
+
 	ParticleGenerator *eng.ParticleGenerator
-	SpriteRenderer    *eng.SpriteRenderer
+	SpriteBatch       *eng.SpriteBatch
 	CPRenderer        *eng.CPRenderer
 	TextRenderer      *eng.TextRenderer
 
+	// emitters are the particle effects, by name, tweakable from the pause menu
+	emitters map[string]*eng.Emitter
+
+	joining  JoinScreen
+	profiles Profiles
+
+	showHUD bool
+	// countdown is the seconds to the start of the round, going negative
+	// while "Go!" shows
+	countdown float64
+	// winners won the round the summary is showing for summaryTime more
+	// seconds. summaryArmed is set once no one holds jump.
+	winners      []*Player
+	summaryTime  float64
+	summaryArmed bool
+
 	shouldRenderCp bool
+}
+
+// atlasFile is an atlas packed ahead of time by cmd/atlas. Without one the
+// images in textureDir are packed as the game starts.
+const (
+	atlasFile  = "assets/atlas.json"
+	textureDir = "assets/textures"
+)
+
+func (g *Game) loadAtlas() {
+	atlas, err := eng.LoadAtlas(atlasFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		if atlas, err = eng.BuildAtlas(textureDir); err != nil {
+			log.Println(err)
+		}
+	}
+	g.Atlas = atlas
+}
+
+// loadTextures finds the images in textureDir, and gives the ones the atlas
+// doesn't have a texture of their own. Particles draw a whole texture each,
+// so theirs are loaded too.
+func (g *Game) loadTextures() {
+	g.textureFiles = map[string]string{}
+	_ = filepath.Walk(textureDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			panic(err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		g.textureFiles[name] = path
+		packed := false
+		if g.Atlas != nil {
+			_, packed = g.Atlas.Regions[name]
+		}
+		if !packed {
+			log.Println("Loading", info.Name())
+			g.LoadTexture(path, name)
+		}
+		return nil
+	})
+	g.particleTexture("particle")
+	for _, e := range g.emitters {
+		g.particleTexture(e.Texture)
+	}
+}
 
-	chaseBananaMode bool
-	randomBombMode  bool
+// particleTexture loads the named image as a texture of its own, if it
+// isn't already, for particles to be drawn with.
+func (g *Game) particleTexture(name string) {
+	path, ok := g.textureFiles[name]
+	if !ok || g.HasTexture(name) {
+		return
+	}
+	log.Println("Loading", path)
+	g.LoadTexture(path, name)
+}
 
-	level string
+// drawSprite draws the named image from the atlas, or from its own texture
+// when the atlas doesn't have it.
+func (g *Game) drawSprite(name string, position, size mgl32.Vec2, rotate float64, color mgl32.Vec3) {
+	if !g.SpriteBatch.DrawRegion(g.Atlas, name, position, size, rotate, color) {
+		g.SpriteBatch.Draw(g.Texture(name), position, size, rotate, color)
+	}
 }
 
+// the part of the world shown at zoom 1, and the size of levels that don't
+// say otherwise
 const (
 	worldWidth  = 1920
 	worldHeight = 1080
@@ -100,6 +184,10 @@ const (
 const (
 	stateActive = iota
 	statePause
+	stateEdit
+	stateJoin
+	stateSummary
+	stateCountdown
 )
 
 const (
@@ -108,105 +196,144 @@ const (
 
 func (g *Game) New(openGlWindow *eng.OpenGlWindow) {
 	g.vsync = true
+	g.showHUD = true
 	g.window = openGlWindow
 	g.gui = NewGui(g)
-	g.Keys = make(map[glfw.Key]bool)
-	g.mouseBody = cp.NewKinematicBody()
+	g.editor = &Editor{}
 	openGlWindow.SetVsync(g.vsync)
 
 	g.ResourceManager = eng.NewResourceManager()
 
-	g.LoadShader("assets/shaders/main.vs.glsl", "assets/shaders/main.fs.glsl", "sprite")
+	g.LoadShader("assets/shaders/batch.vs.glsl", "assets/shaders/batch.fs.glsl", "sprite")
 	g.LoadShader("assets/shaders/particle.vs.glsl", "assets/shaders/particle.fs.glsl", "particle")
 	g.LoadShader("assets/shaders/cp.vs.glsl", "assets/shaders/cp.fs.glsl", "cp")
 	g.LoadShader("assets/shaders/text.vs.glsl", "assets/shaders/text.fs.glsl", "text")
 
-	center := cp.Vector{worldWidth / 2, worldHeight / 2}
-
-	g.projection = mgl32.Ortho(0, worldWidth, worldHeight, 0, -1, 1)
-	g.Shader("sprite").Use().SetInt("sprite", 0).SetMat4("projection", g.projection)
-	g.Shader("particle").Use().SetInt("sprite", 0).SetMat4("projection", g.projection)
-	g.CPRenderer = eng.NewCPRenderer(g.Shader("cp"), g.projection)
-	g.SpriteRenderer = eng.NewSpriteRenderer(g.Shader("sprite"))
+	g.Camera = eng.NewCamera(worldWidth, worldHeight)
+	projection := g.Camera.ViewProjection()
+	g.Shader("sprite").Use().SetInt("image", 0).SetMat4("projection", projection)
+	g.Shader("particle").Use().SetInt("sprite", 0).SetMat4("projection", projection)
+	g.CPRenderer = eng.NewCPRenderer(g.Shader("cp"), projection)
+	g.SpriteBatch = eng.NewSpriteBatch(g.Shader("sprite"))
 	g.TextRenderer = eng.NewTextRenderer(g.Shader("text"), float32(openGlWindow.Width), float32(openGlWindow.Height), "assets/fonts/Roboto-Light.ttf", 24)
 	g.TextRenderer.SetColor(1, 1, 1, 1)
 
-	// Load all textures by name
-	_ = filepath.Walk("assets/textures", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			panic(err)
-		}
-		if info.IsDir() {
-			return nil
-		}
-		log.Println("Loading", info.Name())
-		g.LoadTexture(fmt.Sprintf("assets/textures/%v", info.Name()), strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())))
-		return nil
-	})
+	g.loadAtlas()
+	g.loadEmitters()
+	g.loadTextures()
 
-	g.ParticleGenerator = eng.NewParticleGenerator(g.Shader("particle"), g.Texture("particle"), 500)
+	g.ParticleGenerator = eng.NewParticleGenerator(g.Shader("particle"), g.ResourceManager, g.Texture("particle"), 500)
 
-	g.reset()
+	if g.Seed == 0 {
+		g.Seed = time.Now().UnixNano()
+	}
+	log.Println("Seed", g.Seed)
+	g.World = NewWorld(g.Seed)
+	g.Camera.Bounds = cp.NewBB(0, 0, g.Width, g.Height)
+	g.Camera.LookAt(g.Camera.Bounds)
+
+	if controls, err := LoadControls(bindingsFile); err == nil {
+		g.Controls = controls
+	} else if !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	g.padDown = map[InputAction]bool{}
 
-	glfw.SetJoystickCallback(func(joy, event int) {
-		if glfw.MonitorEvent(event) == glfw.Connected {
-			if joy+1 <= len(g.Players) {
-				log.Println("Joystick reconnected", joy)
-				return
-			}
-			log.Println("Joystick connected", joy)
-			i := len(g.Players)
-			pos := cp.Vector{center.X + rand.Float64()*10, center.Y + rand.Float64()*10}
-			g.Players = append(g.Players, NewPlayer(pos, playerRadius, g))
-			g.Players[i].Color = eng.NextColor()
-			g.Players[i].Joystick = glfw.Joystick(joy)
+	// the shipped mappings, then the player's own to override them
+	for _, filename := range []string{gamepadDBAsset, gamepadDBFile} {
+		if err := g.Gamepads.LoadFile(filename); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
+	glfw.SetJoystickCallback(func(joy glfw.Joystick, event glfw.PeripheralEvent) {
+		if event == glfw.Connected {
+			g.Do(Action{Kind: ActionJoystickConnected, Joystick: JoystickInput(joy)})
 		} else {
 			log.Println("Joystick disconnected", joy)
 		}
 	})
 
-	g.Players = []*Player{}
-	for i := 0; i < 16; i++ {
-		joy := glfw.Joystick(i)
-		if !glfw.JoystickPresent(joy) {
-			break
+	if profiles, err := LoadProfiles(profilesFile); err == nil {
+		g.profiles = profiles
+	} else {
+		if !os.IsNotExist(err) {
+			log.Println(err)
 		}
-		g.Players = append(g.Players, NewPlayer(center, playerRadius, g))
-		g.Players[i].Color = eng.NextColor()
-		g.Players[i].Joystick = joy
+		g.profiles = Profiles{}
 	}
 
+	g.Players = []*Player{}
 	g.state = stateActive
+	if g.ReplayFile != "" {
+		if replay, err := LoadReplay(g.ReplayFile); err != nil {
+			log.Println(err)
+		} else {
+			g.StartReplay(replay)
+		}
+	}
+	if !g.Replaying() {
+		g.join()
+	}
 
 	openGlWindow.SetCursorPosCallback(func(w *glfw.Window, xpos float64, ypos float64) {
 		ww, wh := w.GetSize()
-		g.mouse = g.MouseToSpace(xpos, ypos, ww, wh)
+		g.cursorX, g.cursorY = xpos, ypos
+		g.Mouse = g.MouseToSpace(xpos, ypos, ww, wh)
+		if g.state == stateEdit {
+			g.editor.Moved(g.World, g.Mouse)
+		}
 	})
 
-	openGlWindow.SetKeyCallback(func(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-		if key == glfw.KeyEscape && action == glfw.Press {
-			if g.state == stateActive {
-				g.pause()
-			} else {
-				g.unpause()
+	openGlWindow.SetKeyCallback(func(window *glfw.Window, glfwKey glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		key := Key(glfwKey)
+		if g.gui.keyChange(key, action) {
+			if action == glfw.Release {
+				delete(g.Keys, key)
 			}
+			return
 		}
-		if g.Keys[glfw.KeyE] {
-			g.Bananas = append(g.Bananas, NewBanana(g, g.mouse, 20))
+		if g.gui.rebinding != "" {
+			if action == glfw.Press {
+				g.gui.bindKey(key)
+			} else if action == glfw.Release {
+				delete(g.Keys, key)
+			}
+			return
 		}
-		if g.Keys[glfw.KeyQ] {
-			g.Bombs = append(g.Bombs, NewBomb(g.mouse, 20, g.Space))
+		if key == KeyEscape && action == glfw.Press {
+			if g.state == statePause {
+				g.unpause()
+			} else {
+				g.pause()
+			}
 		}
-		if g.Keys[glfw.KeyF] {
-			g.fullscreen = !g.fullscreen
-			openGlWindow.SetFullscreen(g.fullscreen)
+		if g.state == stateEdit {
+			if action != glfw.Release {
+				g.editor.Key(g.World, key, mods)
+			}
+		} else if g.state != stateJoin && !g.KeyInUse(key) {
+			if action != glfw.Release && g.Bindings.HasKey(InputSpawnBanana, key) {
+				g.Do(Action{Kind: ActionSpawnBanana, Pos: g.Mouse})
+			}
+			if action != glfw.Release && g.Bindings.HasKey(InputSpawnBomb, key) {
+				g.Do(Action{Kind: ActionSpawnBomb, Pos: g.Mouse})
+			}
+			if action == glfw.Press && g.Bindings.HasKey(InputSpawnCrate, key) {
+				g.Do(Action{Kind: ActionSpawnCrate, Pos: g.Mouse})
+			}
+			if action == glfw.Press && g.Bindings.HasKey(InputSpawnBumper, key) {
+				g.Do(Action{Kind: ActionSpawnBumper, Pos: g.Mouse})
+			}
+			if action == glfw.Press && g.Bindings.HasKey(InputSpawnSpring, key) {
+				g.Do(Action{Kind: ActionSpawnSpring, Pos: g.Mouse})
+			}
+			if action == glfw.Press && g.Bindings.HasKey(InputAddPlayer, key) {
+				g.Do(Action{Kind: ActionAddPlayer})
+			}
 		}
-		if g.Keys[glfw.KeyEnter] {
-			i := len(g.Players)
-			pos := cp.Vector{center.X + rand.Float64()*10, center.Y + rand.Float64()*10}
-			g.Players = append(g.Players, NewPlayer(pos, playerRadius, g))
-			g.Players[i].Color = eng.NextColor()
-			g.Players[i].Joystick = glfw.Joystick(-1)
+		if action == glfw.Press && g.Bindings.HasKey(InputFullscreen, key) && !g.KeyInUse(key) {
+			g.toggleFullscreen()
 		}
 		// store for continuous application
 		if action == glfw.Press {
@@ -217,129 +344,128 @@ func (g *Game) New(openGlWindow *eng.OpenGlWindow) {
 	})
 
 	openGlWindow.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
+		if g.state == stateEdit {
+			switch {
+			case button == glfw.MouseButton1 && action == glfw.Press:
+				g.editor.Press(g.World, g.Mouse, mod&glfw.ModShift != 0)
+			case button == glfw.MouseButton1 && action == glfw.Release:
+				g.editor.LeftRelease(g.World, g.Mouse)
+			case button == glfw.MouseButton2 && action == glfw.Press:
+				g.editor.RightPress(g.World, g.Mouse)
+			case button == glfw.MouseButton2 && action == glfw.Release:
+				g.editor.RightRelease(g.World, g.Mouse)
+			}
+			return
+		}
 		if g.state != stateActive {
 			return
 		}
-		// give the mouse click a little radius to make it easier to click small shapes.
-		const clickRadius = 5
-
 		if button == glfw.MouseButton1 {
 			if action == glfw.Press {
-				info := g.Space.PointQueryNearest(g.mouse, clickRadius, NotGrabbableFilter)
-
-				if info.Shape != nil && info.Shape.Body().Mass() < cp.INFINITY {
-					var nearest cp.Vector
-					if info.Distance > 0 {
-						nearest = info.Point
-					} else {
-						nearest = g.mouse
-					}
-
-					body := info.Shape.Body()
-					g.mouseJoint = cp.NewPivotJoint2(g.mouseBody, body, cp.Vector{}, body.WorldToLocal(nearest))
-					g.mouseJoint.SetMaxForce(50000)
-					g.mouseJoint.SetErrorBias(math.Pow(1.0-0.15, 1.0/eng.PhysicsDt))
-					g.Space.AddConstraint(g.mouseJoint)
-				} else {
-					leftDown := g.mouse.Clone()
-					g.leftDown = &leftDown
-					wall := NewWall(g, *g.leftDown, g.mouse)
-					g.drawingWallShape = wall
-					g.Walls = append(g.Walls, g.drawingWallShape)
-				}
-				return
-			}
-			// mouse up
-			if g.mouseJoint != nil {
-				g.Space.RemoveConstraint(g.mouseJoint)
-				g.mouseJoint = nil
-				return
-			}
-			if g.leftDown != nil {
-				g.leftDown = nil
+				g.Do(Action{Kind: ActionGrab, Pos: g.Mouse, Material: g.editor.material})
+			} else {
+				g.Do(Action{Kind: ActionRelease})
 			}
 			return
 		}
 
 		if button == glfw.MouseButton2 {
 			if action == glfw.Press {
-				rightDown := g.mouse.Clone()
+				rightDown := g.Mouse.Clone()
 				g.rightDown = &rightDown
 			} else {
 				g.rightDown = nil
-
-				info := g.Space.PointQueryNearest(g.mouse, clickRadius, NotGrabbableFilter)
-
-				if info.Shape != nil {
-					if segment, ok := info.Shape.Class.(*cp.Segment); ok {
-						for i, w := range g.Walls {
-							if segment == w.Segment {
-								g.Walls = append(g.Walls[:i], g.Walls[i+1:]...)
-								g.Space.AddPostStepCallback(func(space *cp.Space, key interface{}, data interface{}) {
-									space.RemoveShape(w.Shape)
-									space.RemoveBody(w.Body())
-								}, nil, nil)
-								break
-							}
-						}
-					}
-				}
+				g.Do(Action{Kind: ActionDeleteWall, Pos: g.Mouse})
 			}
 		}
 	})
 }
 
 func (g *Game) Update(dt float64) {
-	if g.state == statePause {
+	g.updateCamera(dt)
+	// particles keep moving while paused, so the particle panel can show them off
+	g.ParticleGenerator.Update(dt)
+	switch g.state {
+	case stateJoin:
+		g.pollJoin()
+	case stateCountdown, stateSummary, stateActive:
+		g.updateRound(dt)
+	}
+	if g.state != stateActive {
 		return
 	}
 
-	if g.chaseBananaMode && len(g.Bananas) == 0 {
-		x := rand.Intn(worldWidth)
-		y := rand.Intn(worldHeight)
-		banana := NewBanana(g, cp.Vector{float64(x), float64(y)}, 20)
-		banana.SetVelocity(float64(rand.Intn(2000)-1000), float64(rand.Intn(2000)-1000))
-		g.Bananas = append(g.Bananas, banana)
-	}
-	if g.randomBombMode && len(g.Bombs) == 0 {
-		x := rand.Intn(worldWidth)
-		y := rand.Intn(worldHeight)
-		bomb := NewBomb(cp.Vector{float64(x), float64(y)}, 20, g.Space)
-		bomb.SetVelocity(float64(rand.Intn(2000)-1000), float64(rand.Intn(2000)-1000))
-		g.Bombs = append(g.Bombs, bomb)
+	g.pollGamepadActions()
+	g.World.Update(dt)
+	g.applyProfiles()
+	g.effects(dt)
+	if winners, over := g.Winners(); over {
+		g.endRound(winners)
 	}
+}
 
-	// update mouse body
-	newPoint := g.mouseBody.Position().Lerp(g.mouse, 0.25)
-	g.mouseBody.SetVelocityVector(newPoint.Sub(g.mouseBody.Position()).Mult(1.0 / eng.PhysicsDt))
-	g.mouseBody.SetPosition(newPoint)
-
-	if g.leftDown != nil {
-		g.drawingWallShape.SetEndpoints(*g.leftDown, g.mouse)
-	} else if g.drawingWallShape != nil {
-		g.Space.AddShape(g.drawingWallShape.Shape)
-		g.drawingWallShape = nil
+// updateCamera follows the players while playing and shows the whole level
+// otherwise.
+func (g *Game) updateCamera(dt float64) {
+	world := cp.NewBB(0, 0, g.Width, g.Height)
+	g.Camera.Bounds = world
+	if g.state == stateActive && len(g.Players) > 0 {
+		g.Camera.Follow(playersBB(g.Players), dt)
+	} else {
+		g.Camera.Follow(world, dt)
 	}
+	g.split.Update(g, dt)
 
-	for i := range g.Bombs {
-		g.Bombs[i].Update(g, dt)
-	}
-	// Filter out gone bombs without discarding live ones.
-	out := g.Bombs[:0]
-	for _, b := range g.Bombs {
-		if b.state != bombStateGone {
-			out = append(out, b)
+	ww, wh := g.window.GetSize()
+	if mouse := g.MouseToSpace(g.cursorX, g.cursorY, ww, wh); mouse != g.Mouse {
+		g.Mouse = mouse
+		if g.state == stateEdit {
+			g.editor.Moved(g.World, g.Mouse)
 		}
 	}
-	g.Bombs = out
-	for i := range g.Bananas {
-		g.Bananas[i].Update(g, dt)
-	}
-	for i := range g.Players {
-		g.Players[i].Update(g, dt)
+}
+
+// pollGamepadActions fires the game-wide actions bound to the buttons and
+// axes of the players' gamepads.
+func (g *Game) pollGamepadActions() {
+	for _, action := range []InputAction{InputSpawnBanana, InputSpawnBomb, InputSpawnCrate, InputSpawnBumper, InputSpawnSpring, InputFullscreen, InputAddPlayer} {
+		down := false
+		for _, p := range g.Players {
+			if joy, ok := p.Input.(JoystickInput); ok {
+				pad := g.Gamepads.State(joy)
+				if g.Bindings.Pressed(action, nil, &pad) {
+					down = true
+					break
+				}
+			}
+		}
+		pressed := down && !g.padDown[action]
+		g.padDown[action] = down
+		if !pressed {
+			continue
+		}
+		switch action {
+		case InputSpawnBanana:
+			g.Do(Action{Kind: ActionSpawnBanana, Pos: g.Mouse})
+		case InputSpawnBomb:
+			g.Do(Action{Kind: ActionSpawnBomb, Pos: g.Mouse})
+		case InputSpawnCrate:
+			g.Do(Action{Kind: ActionSpawnCrate, Pos: g.Mouse})
+		case InputSpawnBumper:
+			g.Do(Action{Kind: ActionSpawnBumper, Pos: g.Mouse})
+		case InputSpawnSpring:
+			g.Do(Action{Kind: ActionSpawnSpring, Pos: g.Mouse})
+		case InputFullscreen:
+			g.toggleFullscreen()
+		case InputAddPlayer:
+			g.Do(Action{Kind: ActionAddPlayer})
+		}
 	}
+}
 
-	g.Space.Step(dt)
+func (g *Game) toggleFullscreen() {
+	g.fullscreen = !g.fullscreen
+	g.window.SetFullscreen(g.fullscreen)
 }
 
 func (g *Game) Render(alpha float64) {
@@ -351,7 +477,82 @@ func (g *Game) Render(alpha float64) {
 		log.Printf("update viewport %#v\n", g.window)
 	}
 
-	g.SpriteRenderer.DrawSprite(g.Texture("background"), mgl32.Vec2{worldWidth / 2, worldHeight / 2}, mgl32.Vec2{worldWidth, worldHeight}, 0, eng.White)
+	views := g.split.Views(g.Camera)
+	for _, v := range views {
+		if len(views) > 1 {
+			v.viewport(g.window.ViewportWidth, g.window.ViewPortHeight)
+		}
+		g.renderWorld(v.Camera, alpha)
+	}
+	if len(views) > 1 {
+		gl.Viewport(0, 0, int32(g.window.ViewportWidth), int32(g.window.ViewPortHeight))
+	}
+
+	ww, wh := g.window.GetSize()
+	for _, v := range views {
+		for _, p := range g.Players {
+			label := p.Name
+			if label == "" {
+				label = p.Input.Label(g.World)
+			}
+			if label != "" {
+				pos := p.SmoothPos(alpha)
+				x, y := v.toWindow(cp.Vector{float64(pos.X()), float64(pos.Y()) - p.Circle.Radius() - 8}, ww, wh)
+				// text isn't clipped to the view, so leave out labels of
+				// players other panes show
+				if !v.contains(x/float64(ww), y/float64(wh)) {
+					continue
+				}
+				g.TextRenderer.PrintAligned(label, x, y, 1, eng.AlignCenter)
+			}
+		}
+	}
+
+	if g.state == stateEdit {
+		drawing := "walls"
+		if g.editor.drawPlatforms {
+			drawing = "platforms"
+		}
+		help := fmt.Sprintf("Editing: drag walls or their ends, right drag draws %v (P switches), Del deletes, M changes material (now %v), B adds or removes a block, R a rope and Shift+R a chain, Ctrl+Z/Ctrl+Y undo/redo, G snaps, Esc for menu", drawing, g.editor.material)
+		if p := g.editor.platform; p != nil {
+			help += fmt.Sprintf(". Platform: drag its path or waypoints, W adds a waypoint, O changes motion (now %v), [ and ] change speed (now %.4g)", p.Motion, p.Speed)
+		}
+		g.TextRenderer.PrintWrapped(help, 10, 30, float64(ww)-20, 1, eng.AlignLeft)
+	}
+
+	if g.showHUD && (g.state == stateActive || g.state == statePause || g.state == stateCountdown) {
+		g.renderHUD()
+		g.renderTimer(ww)
+	}
+	if g.state == stateActive || g.state == stateCountdown {
+		g.renderCountdown(ww, wh)
+	}
+	if g.state == stateSummary {
+		g.renderSummary(ww, wh)
+	}
+
+	if g.state == stateJoin {
+		if len(g.joining.Seats) == 0 {
+			g.TextRenderer.PrintAligned("Press jump on a controller or keyboard to join", float64(ww)/2, float64(wh)/2, 1, eng.AlignCenter)
+		}
+	} else if len(g.Players) == 0 {
+		g.TextRenderer.PrintAligned("Connect controllers or press ENTER to use keyboard", float64(ww)/2, float64(wh)/2, 1, eng.AlignCenter)
+	}
+
+	if g.state == statePause || g.state == stateJoin {
+		g.gui.Render()
+	}
+}
+
+// renderWorld draws everything in the world as cam sees it.
+func (g *Game) renderWorld(cam *eng.Camera, alpha float64) {
+	projection := cam.ViewProjection()
+	g.Shader("sprite").Use().SetMat4("projection", projection)
+	g.Shader("particle").Use().SetMat4("projection", projection)
+	g.CPRenderer.SetProjection(projection)
+
+	g.drawSprite(g.level.Background, mgl32.Vec2{float32(g.Width) / 2, float32(g.Height) / 2}, mgl32.Vec2{float32(g.Width), float32(g.Height)}, 0, eng.White)
+	g.SpriteBatch.Flush()
 
 	{
 		g.CPRenderer.Clear()
@@ -361,129 +562,102 @@ func (g *Game) Render(alpha float64) {
 			for i := range g.Walls {
 				g.Walls[i].Draw(g, alpha)
 			}
+			for i := range g.Platforms {
+				g.Platforms[i].Draw(g, alpha)
+			}
+			for i := range g.Ropes {
+				g.Ropes[i].Draw(g)
+			}
+			for i := range g.Bumpers {
+				g.Bumpers[i].Draw(g)
+			}
+			for i := range g.Springs {
+				g.Springs[i].Draw(g)
+			}
+		}
+		for i := range g.Blocks {
+			g.Blocks[i].Draw(g, alpha)
+		}
+		for i := range g.Crates {
+			g.Crates[i].Draw(g, alpha)
+		}
+		if g.shouldRenderCp || g.state == stateEdit {
+			for i := range g.Platforms {
+				g.Platforms[i].DrawPath(g)
+			}
+		}
+		if g.state == stateEdit {
+			g.editor.Draw(g)
 		}
 		g.CPRenderer.Flush()
+		// and the textures of the blocks and crates
+		g.SpriteBatch.Flush()
 	}
 
-	if len(g.Players) == 0 {
-		g.TextRenderer.Print("Connect controllers or press ENTER to use keyboard", float64(g.window.Width)/2.-250., float64(g.window.Height)/2., 1)
-	}
-
-	//g.SpriteRenderer.DrawSprite(g.Texture("banana"), V(g.mouse), mgl32.Vec2{100, 100}, 0, mgl32.Vec3{1, 0, 0})
-
+	// one flush per kind of thing, so players stay on top of particles, bombs
+	// and fruit
 	for i := range g.Bananas {
-		g.Bananas[i].Draw(g.SpriteRenderer, alpha)
+		g.Bananas[i].Draw(g, alpha)
 	}
+	g.SpriteBatch.Flush()
 	for i := range g.Bombs {
 		g.Bombs[i].Draw(g, alpha)
 	}
+	g.SpriteBatch.Flush()
+	g.ParticleGenerator.Draw()
 	for i := range g.Players {
 		g.Players[i].Draw(g, alpha)
 	}
-
-	if g.state == statePause {
-		g.gui.Render()
-	}
+	g.SpriteBatch.Flush()
 }
 
 func (g *Game) Close() {
+	g.StopRecording()
 	g.gui.Destroy()
 	g.Clear()
 }
 
 func (g *Game) pause() {
-	g.state = statePause
-}
-
-func (g *Game) unpause() {
-	g.state = stateActive
-}
-
-func (g *Game) reset() {
-	g.Space = cp.NewSpace()
-	g.Space.Iterations = 10
-	g.Space.SetGravity(cp.Vector{0, Gravity})
-
-	bananaCollisionHandler := g.Space.NewCollisionHandler(collisionBanana, collisionPlayer)
-	bananaCollisionHandler.PreSolveFunc = BananaPreSolve
-	bananaCollisionHandler.UserData = g
-
-	bombCollisionHandler := g.Space.NewWildcardCollisionHandler(collisionBomb)
-	bombCollisionHandler.PreSolveFunc = BombPreSolve
-
-	g.Space.NewWildcardCollisionHandler(collisionWall).PreSolveFunc = WallPreSolve
-
-	center := cp.Vector{worldWidth / 2, worldHeight / 2}
-
-	// load the initial level
-	if err := g.loadLevel("assets/levels/initial.json"); err != nil {
-		panic(err)
+	if g.state == stateEdit {
+		g.editor.Release(g.World, g.Mouse)
 	}
-
-	var players []*Player
-	for _, p := range g.Players {
-		if p.Joystick == glfw.Joystick(-1) {
-			// remove players created with "enter" for when the kids make too many players
-			continue
-		}
-		pos := cp.Vector{center.X + rand.Float64()*10, center.Y + rand.Float64()*10}
-		p.Reset(pos, playerRadius, g)
-		players = append(players, p)
+	// resume the countdown or summary the menu interrupted, and play otherwise
+	g.resumeState = stateActive
+	if g.state == stateCountdown || g.state == stateSummary {
+		g.resumeState = g.state
 	}
-	g.Players = players
-	g.Bananas = []*Banana{}
-	g.Bombs = []*Bomb{}
+	g.state = statePause
 }
 
-func (g *Game) MouseToSpace(x, y float64, ww, wh int) cp.Vector {
-	model := mgl32.Translate3D(0, 0, 0)
-	obj, err := mgl32.UnProject(mgl32.Vec3{float32(x), float32(float64(wh) - y), 0}, model, g.projection, 0, 0, ww, wh)
-	if err != nil {
-		panic(err)
+// edit stops the simulation and opens the level editor. Edits can't be
+// recorded, so any recording or replay stops.
+func (g *Game) edit() {
+	g.StopRecording()
+	g.StopReplay()
+	g.editor.selected, g.editor.platform = nil, nil
+	// platforms are edited where they start
+	for _, p := range g.Platforms {
+		p.Reset()
 	}
-
-	return cp.Vector{float64(obj.X()), float64(obj.Y())}
+	g.state = stateEdit
 }
 
-func (g *Game) saveLevel(filename string) {
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	type entry struct {
-		A, B cp.Vector
-	}
-	var data []entry
-	for _, w := range g.Walls {
-		data = append(data, entry{w.A(), w.B()})
-	}
-	if err = json.NewEncoder(file).Encode(data); err != nil {
-		log.Println(err)
-	}
+func (g *Game) unpause() {
+	g.state = g.resumeState
 }
 
-func (g *Game) loadLevel(name string) error {
-	file, err := os.Open(name)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	type entry struct {
-		A, B cp.Vector
-	}
-	var data []entry
-	if err = json.NewDecoder(file).Decode(&data); err != nil {
-		log.Println(err)
-		return err
-	}
+func (g *Game) reset() {
+	g.World.reset()
+}
 
-	g.Walls = []*Wall{}
-	for _, w := range data {
-		wall := NewWall(g, w.A, w.B)
-		g.Space.AddShape(wall.Segment.Shape)
-		g.Walls = append(g.Walls, wall)
+// MouseToSpace converts window coordinates to the world, through whichever
+// view they are in.
+func (g *Game) MouseToSpace(x, y float64, ww, wh int) cp.Vector {
+	views := g.split.Views(g.Camera)
+	for _, v := range views {
+		if v.contains(x/float64(ww), y/float64(wh)) {
+			return v.toWorld(x, y, ww, wh)
+		}
 	}
-
-	return nil
+	return views[0].toWorld(x, y, ww, wh)
 }
@@ -0,0 +1,381 @@
+package fam
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const (
+	gamepadDBAsset = "assets/gamecontrollerdb.txt"
+	// gamepadDBFile is where players can put their own mappings
+	gamepadDBFile = "gamecontrollerdb.txt"
+)
+
+// GamepadButton is a button of the standard (Xbox style) gamepad layout.
+type GamepadButton int
+
+const (
+	ButtonA GamepadButton = iota
+	ButtonB
+	ButtonX
+	ButtonY
+	ButtonBack
+	ButtonGuide
+	ButtonStart
+	ButtonLeftStick
+	ButtonRightStick
+	ButtonLeftShoulder
+	ButtonRightShoulder
+	ButtonDpadUp
+	ButtonDpadDown
+	ButtonDpadLeft
+	ButtonDpadRight
+	gamepadButtonCount
+)
+
+// GamepadAxis is an axis of the standard gamepad layout. Sticks go from -1 to
+// 1, positive being right and down, and triggers from 0 to 1.
+type GamepadAxis int
+
+const (
+	AxisLeftX GamepadAxis = iota
+	AxisLeftY
+	AxisRightX
+	AxisRightY
+	AxisLeftTrigger
+	AxisRightTrigger
+	gamepadAxisCount
+)
+
+// the names SDL mapping strings use
+var (
+	gamepadButtonNames = [gamepadButtonCount]string{"a", "b", "x", "y", "back", "guide", "start", "leftstick", "rightstick", "leftshoulder", "rightshoulder", "dpup", "dpdown", "dpleft", "dpright"}
+	gamepadAxisNames   = [gamepadAxisCount]string{"leftx", "lefty", "rightx", "righty", "lefttrigger", "righttrigger"}
+)
+
+var gamepadButtonLabels = [gamepadButtonCount]string{"A", "B", "X", "Y", "Back", "Guide", "Start", "Left stick", "Right stick", "Left shoulder", "Right shoulder", "D-pad up", "D-pad down", "D-pad left", "D-pad right"}
+var gamepadAxisLabels = [gamepadAxisCount]string{"Left stick X", "Left stick Y", "Right stick X", "Right stick Y", "Left trigger", "Right trigger"}
+
+func (b GamepadButton) String() string {
+	if b < 0 || b >= gamepadButtonCount {
+		return fmt.Sprintf("Button %d", int(b))
+	}
+	return gamepadButtonLabels[b]
+}
+
+func (a GamepadAxis) String() string {
+	if a < 0 || a >= gamepadAxisCount {
+		return fmt.Sprintf("Axis %d", int(a))
+	}
+	return gamepadAxisLabels[a]
+}
+
+// GamepadState is a controller read through a mapping.
+type GamepadState struct {
+	Buttons [gamepadButtonCount]bool
+	Axes    [gamepadAxisCount]float64
+}
+
+// JoystickState is a controller as the joystick API reports it.
+type JoystickState struct {
+	Axes    []float32
+	Buttons []byte
+	// Hats are bitmasks of hatUp, hatRight, hatDown and hatLeft.
+	Hats []byte
+}
+
+const (
+	hatUp    = 1
+	hatRight = 2
+	hatDown  = 4
+	hatLeft  = 8
+)
+
+// mappingInput is the joystick side of one element of a mapping, like "b0",
+// "-a1", "a2~" or "h0.4".
+type mappingInput struct {
+	kind  byte // 'b', 'a' or 'h', 0 when unmapped
+	index int
+	// hat direction for 'h'
+	mask byte
+	// axis range for 'a': 0 for the whole axis, 1 for 0..1 and -1 for -1..0
+	half   int
+	invert bool
+}
+
+// mappingOutput maps an input to a gamepad axis. half is set for elements
+// like "+leftx:b3" that drive one direction of the axis.
+type mappingOutput struct {
+	mappingInput
+	half int
+}
+
+// GamepadMapping turns the raw axes, buttons and hats of one controller
+// model into the standard gamepad layout. It is parsed from an SDL
+// gamecontrollerdb line.
+type GamepadMapping struct {
+	GUID     string
+	Name     string
+	Platform string
+
+	buttons [gamepadButtonCount]mappingInput
+	axes    [gamepadAxisCount][]mappingOutput
+}
+
+// ParseGamepadMapping parses one line of a gamecontrollerdb.txt, e.g.
+//
+//	030000005e0400008e02000014010000,Xbox 360 Controller,a:b0,b:b1,leftx:a0,dpup:h0.1,platform:Linux,
+func ParseGamepadMapping(line string) (*GamepadMapping, error) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("gamepad mapping %q: missing GUID or name", line)
+	}
+	m := &GamepadMapping{
+		GUID: strings.ToLower(fields[0]),
+		Name: fields[1],
+	}
+	for _, field := range fields[2:] {
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("gamepad mapping %v: bad element %q", m.Name, field)
+		}
+		if key == "platform" {
+			m.Platform = value
+			continue
+		}
+
+		half := 0
+		if key[0] == '+' || key[0] == '-' {
+			half = sign(key[0])
+			key = key[1:]
+			if key == "" {
+				return nil, fmt.Errorf("gamepad mapping %v: bad element %q", m.Name, field)
+			}
+		}
+		in, err := parseMappingInput(value)
+		if err != nil {
+			return nil, fmt.Errorf("gamepad mapping %v: %v", m.Name, err)
+		}
+		if b := indexOf(gamepadButtonNames[:], key); b >= 0 {
+			m.buttons[b] = in
+		} else if a := indexOf(gamepadAxisNames[:], key); a >= 0 {
+			m.axes[a] = append(m.axes[a], mappingOutput{in, half})
+		}
+		// anything else (paddles, touchpad, misc buttons) isn't used
+	}
+	return m, nil
+}
+
+func parseMappingInput(s string) (mappingInput, error) {
+	var in mappingInput
+	if s[0] == '+' || s[0] == '-' {
+		in.half = sign(s[0])
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "~") {
+		in.invert = true
+		s = s[:len(s)-1]
+	}
+	if len(s) < 2 {
+		return in, fmt.Errorf("bad input %q", s)
+	}
+	in.kind = s[0]
+	switch in.kind {
+	case 'a', 'b':
+		n, err := strconv.Atoi(s[1:])
+		if err != nil {
+			return in, fmt.Errorf("bad input %q", s)
+		}
+		in.index = n
+	case 'h':
+		hat, mask, ok := strings.Cut(s[1:], ".")
+		n, err := strconv.Atoi(hat)
+		m, err2 := strconv.Atoi(mask)
+		if !ok || err != nil || err2 != nil {
+			return in, fmt.Errorf("bad hat %q", s)
+		}
+		in.index, in.mask = n, byte(m)
+	default:
+		return in, fmt.Errorf("bad input %q", s)
+	}
+	return in, nil
+}
+
+func sign(c byte) int {
+	if c == '-' {
+		return -1
+	}
+	return 1
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Map reads raw through the mapping.
+func (m *GamepadMapping) Map(raw JoystickState) GamepadState {
+	var s GamepadState
+	for b, in := range m.buttons {
+		s.Buttons[b] = in.read(raw) > 0.5
+	}
+	for a, outs := range m.axes {
+		for _, out := range outs {
+			v := out.read(raw)
+			switch {
+			case out.half != 0:
+				// a button or half axis driving one direction
+				v *= float64(out.half)
+			case GamepadAxis(a) >= AxisLeftTrigger && out.kind == 'a' && out.mappingInput.half == 0:
+				// triggers rest at -1 on the raw axis
+				v = (v + 1) / 2
+			}
+			if v != 0 {
+				s.Axes[a] = v
+			}
+		}
+	}
+	return s
+}
+
+// read returns a button or hat as 0 or 1 and an axis in its range: -1 to 1
+// for a whole axis and 0 to 1 for a half one.
+func (in mappingInput) read(raw JoystickState) float64 {
+	switch in.kind {
+	case 'b':
+		if in.index < len(raw.Buttons) && raw.Buttons[in.index] != 0 {
+			return 1
+		}
+	case 'h':
+		if in.index < len(raw.Hats) && raw.Hats[in.index]&in.mask != 0 {
+			return 1
+		}
+	case 'a':
+		if in.index >= len(raw.Axes) {
+			return 0
+		}
+		v := float64(raw.Axes[in.index])
+		if in.invert {
+			v = -v
+		}
+		switch in.half {
+		case 1:
+			return max(v, 0)
+		case -1:
+			return max(-v, 0)
+		}
+		return v
+	}
+	return 0
+}
+
+// defaultGamepadMapping is used for controllers that aren't in the database.
+// It's the layout of an Xbox controller on most drivers.
+var defaultGamepadMapping, _ = ParseGamepadMapping("default,Default Gamepad," +
+	"a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,guide:b8,leftstick:b9,rightstick:b10," +
+	"leftx:a0,lefty:a1,lefttrigger:a2,rightx:a3,righty:a4,righttrigger:a5," +
+	"dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,")
+
+// GamepadDB holds the mappings for the current platform.
+type GamepadDB struct {
+	byGUID map[string]*GamepadMapping
+	byName map[string]*GamepadMapping
+}
+
+func NewGamepadDB() *GamepadDB {
+	return &GamepadDB{
+		byGUID: map[string]*GamepadMapping{},
+		byName: map[string]*GamepadMapping{},
+	}
+}
+
+// sdlPlatform is the platform name SDL mappings use for this OS.
+func sdlPlatform() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "Windows"
+	case "darwin":
+		return "Mac OS X"
+	case "linux":
+		return "Linux"
+	}
+	return runtime.GOOS
+}
+
+// Load reads the mappings for platform from a gamecontrollerdb.txt, skipping
+// comments and other platforms. Later mappings replace earlier ones.
+func (db *GamepadDB) Load(r io.Reader, platform string) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		m, err := ParseGamepadMapping(line)
+		if err != nil {
+			return fmt.Errorf("line %v: %v", n, err)
+		}
+		if m.Platform != "" && platform != "" && m.Platform != platform {
+			continue
+		}
+		db.Add(m)
+	}
+	return scanner.Err()
+}
+
+// LoadFile loads a gamecontrollerdb.txt for this platform.
+func (db *GamepadDB) LoadFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	if err = db.Load(file, sdlPlatform()); err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+	return nil
+}
+
+func (db *GamepadDB) Add(m *GamepadMapping) {
+	db.byGUID[m.GUID] = m
+	db.byName[strings.ToLower(m.Name)] = m
+}
+
+// Lookup finds the mapping for a controller by GUID, then by name. It never
+// returns nil, falling back to a mapping for Xbox style controllers.
+func (db *GamepadDB) Lookup(guid, name string) *GamepadMapping {
+	if m, ok := db.byGUID[strings.ToLower(guid)]; ok && guid != "" {
+		return m
+	}
+	if m, ok := db.byName[strings.ToLower(name)]; ok {
+		return m
+	}
+	return defaultGamepadMapping
+}
+
+// State reads a connected joystick through the mapping for its GUID.
+func (db *GamepadDB) State(input JoystickInput) GamepadState {
+	joy := glfw.Joystick(input)
+	raw := JoystickState{Axes: joy.GetAxes()}
+	for _, button := range joy.GetButtons() {
+		raw.Buttons = append(raw.Buttons, byte(button))
+	}
+	for _, hat := range joy.GetHats() {
+		raw.Hats = append(raw.Hats, byte(hat))
+	}
+	return db.Lookup(joy.GetGUID(), joy.GetName()).Map(raw)
+}
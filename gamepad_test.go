@@ -0,0 +1,160 @@
+package fam
+
+import (
+	"strings"
+	"testing"
+)
+
+const xbox360Mapping = "030000005e0400008e02000014010000,Xbox 360 Controller," +
+	"a:b0,b:b1,x:b2,y:b3,back:b6,guide:b8,start:b7,leftstick:b9,rightstick:b10,leftshoulder:b4,rightshoulder:b5," +
+	"dpup:h0.1,dpdown:h0.4,dpleft:h0.8,dpright:h0.2," +
+	"leftx:a0,lefty:a1,rightx:a3,righty:a4,lefttrigger:a2,righttrigger:a5,platform:Linux,"
+
+func TestParseGamepadMapping(t *testing.T) {
+	m, err := ParseGamepadMapping(xbox360Mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.GUID != "030000005e0400008e02000014010000" || m.Name != "Xbox 360 Controller" || m.Platform != "Linux" {
+		t.Errorf("got GUID %q, name %q and platform %q", m.GUID, m.Name, m.Platform)
+	}
+}
+
+func TestGamepadMappingMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements string
+		raw      JoystickState
+		want     func(s *GamepadState)
+	}{
+		{
+			name:     "full mapping",
+			elements: xbox360Mapping[strings.Index(xbox360Mapping, "a:b0"):],
+			raw: JoystickState{
+				Axes:    []float32{.5, -.25, -1, 0, 0, 1},
+				Buttons: []byte{1, 0, 0, 0, 0, 1, 0, 1, 0, 0, 0},
+				Hats:    []byte{hatUp | hatLeft},
+			},
+			want: func(s *GamepadState) {
+				s.Buttons[ButtonA] = true
+				s.Buttons[ButtonRightShoulder] = true
+				s.Buttons[ButtonStart] = true
+				s.Buttons[ButtonDpadUp] = true
+				s.Buttons[ButtonDpadLeft] = true
+				s.Axes[AxisLeftX] = .5
+				s.Axes[AxisLeftY] = -.25
+				// triggers rest at -1 on the raw axis
+				s.Axes[AxisLeftTrigger] = 0
+				s.Axes[AxisRightTrigger] = 1
+			},
+		},
+		{
+			name:     "buttons drive half axes",
+			elements: "+leftx:b1,-leftx:b0,+lefty:b2",
+			raw:      JoystickState{Buttons: []byte{1, 0, 1}},
+			want: func(s *GamepadState) {
+				s.Axes[AxisLeftX] = -1
+				s.Axes[AxisLeftY] = 1
+			},
+		},
+		{
+			name:     "half axes drive buttons",
+			elements: "dpleft:-a0,dpright:+a0,dpup:-a1,dpdown:+a1",
+			raw:      JoystickState{Axes: []float32{-1, .25}},
+			want: func(s *GamepadState) {
+				s.Buttons[ButtonDpadLeft] = true
+			},
+		},
+		{
+			name:     "half axis trigger",
+			elements: "lefttrigger:+a2,righttrigger:-a2",
+			raw:      JoystickState{Axes: []float32{0, 0, .5}},
+			want: func(s *GamepadState) {
+				s.Axes[AxisLeftTrigger] = .5
+			},
+		},
+		{
+			name:     "inverted axes",
+			elements: "lefty:a1~,righty:+a3~",
+			raw:      JoystickState{Axes: []float32{0, .25, 0, -.5}},
+			want: func(s *GamepadState) {
+				s.Axes[AxisLeftY] = -.25
+				s.Axes[AxisRightY] = .5
+			},
+		},
+		{
+			name:     "hats",
+			elements: "dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,a:h1.4",
+			raw:      JoystickState{Hats: []byte{hatDown | hatRight, hatDown}},
+			want: func(s *GamepadState) {
+				s.Buttons[ButtonDpadDown] = true
+				s.Buttons[ButtonDpadRight] = true
+				s.Buttons[ButtonA] = true
+			},
+		},
+		{
+			name:     "unknown elements are ignored",
+			elements: "a:b0,misc1:b1,paddle1:b2",
+			raw:      JoystickState{Buttons: []byte{0, 1, 1}},
+			want:     func(s *GamepadState) {},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := ParseGamepadMapping("guid,Test," + test.elements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want GamepadState
+			test.want(&want)
+			if got := m.Map(test.raw); got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseGamepadMappingErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"guidonly",
+		"guid,Test,a",
+		"guid,Test,:b0",
+		"guid,Test,a:",
+		"guid,Test,+:a0",
+		"guid,Test,a:+",
+		"guid,Test,leftx:~",
+		"guid,Test,a:b",
+		"guid,Test,a:bx",
+		"guid,Test,a:x0",
+		"guid,Test,dpup:h0",
+		"guid,Test,dpup:hx.1",
+	} {
+		if m, err := ParseGamepadMapping(line); err == nil {
+			t.Errorf("%q parsed as %+v", line, m)
+		}
+	}
+}
+
+func TestGamepadDBLookup(t *testing.T) {
+	db := NewGamepadDB()
+	err := db.Load(strings.NewReader("# comment\n\n"+
+		xbox360Mapping+"\n"+
+		"03000000ffff00000000000000000000,Windows Pad,a:b1,platform:Windows,\n"), "Linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xbox := db.Lookup("030000005E0400008E02000014010000", "")
+	if xbox.Name != "Xbox 360 Controller" {
+		t.Errorf("lookup by GUID found %q", xbox.Name)
+	}
+	if m := db.Lookup("", "xbox 360 controller"); m != xbox {
+		t.Errorf("lookup by name found %q", m.Name)
+	}
+	if m := db.Lookup("unknown", "Unknown Pad"); m != defaultGamepadMapping {
+		t.Errorf("unknown pad found %q", m.Name)
+	}
+	if m := db.Lookup("03000000ffff00000000000000000000", "Windows Pad"); m != defaultGamepadMapping {
+		t.Errorf("mapping for another platform found %q", m.Name)
+	}
+}
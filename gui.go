@@ -3,10 +3,15 @@ package fam
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"sort"
+	"strconv"
 
-	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/inkyblackness/imgui-go"
+	"github.com/jakecoffman/fam/eng"
 	"github.com/jakecoffman/fam/gui"
 	"github.com/sqweek/dialog"
 )
@@ -20,6 +25,31 @@ type Gui struct {
 
 	showDemoWindow    bool
 	showAnotherWindow bool
+
+	// seed is the text in the seed box, applied by "Reset with seed".
+	seed string
+
+	// botBehaviour is what "Add bot" adds
+	botBehaviour BotBehaviour
+
+	showControls bool
+	// rebinding is the action waiting for a key, button or axis to bind in
+	// rebindTarget, taking keys or gamepad input as rebindKeys and rebindPads say.
+	// rebindID names the section of the controls screen it's in.
+	rebinding    InputAction
+	rebindID     string
+	rebindTarget Bindings
+	rebindKeys   bool
+	rebindPads   bool
+	// rebindRest are the gamepads when rebinding started, so a button or
+	// axis already held isn't bound straight away.
+	rebindRest map[JoystickInput]GamepadState
+	// rebindError says why the last key pressed wasn't bound
+	rebindError string
+
+	showParticles bool
+	// emitter is the name of the emitter the particles panel is tweaking
+	emitter string
 }
 
 func NewGui(game *Game) *Gui {
@@ -51,6 +81,8 @@ func NewGui(game *Game) *Gui {
 	return g
 }
 
+var splitScreenNames = map[int]string{0: "Off", 1: "Off", 2: "2 panes", 3: "3 panes", 4: "4 panes"}
+
 func (gui *Gui) Destroy() {
 	gui.renderer.Dispose()
 	gui.Context.Destroy()
@@ -103,13 +135,37 @@ func (gui *Gui) Render() {
 	}
 
 	// 2. Show a simple window that we create ourselves. We use a Begin/End pair to created a named window.
-	{
+	if gui.game.state == stateJoin {
+		gui.renderJoin()
+	} else {
 		imgui.Begin("Menu")
 
 		if imgui.Button("Resume game") {
 			gui.game.unpause()
 		}
 
+		if imgui.Button("Edit level") {
+			gui.game.edit()
+		}
+		imgui.SameLine()
+		if imgui.Button("Join screen") {
+			gui.game.join()
+		}
+
+		imgui.InputText("Level name", &gui.game.level.Name)
+		imgui.InputText("Author", &gui.game.level.Author)
+		if gui.game.Recording() || gui.game.Replaying() {
+			// resizing would change the world under the recording or replay
+			imgui.Text(fmt.Sprintf("Level size %.0f x %.0f", gui.game.Width, gui.game.Height))
+		} else {
+			width, height := float32(gui.game.Width), float32(gui.game.Height)
+			resized := imgui.DragFloatV("Level width", &width, 10, 200, 20000, "%.0f", 1)
+			resized = imgui.DragFloatV("Level height", &height, 10, 200, 20000, "%.0f", 1) || resized
+			if resized {
+				gui.game.SetSize(float64(width), float64(height))
+			}
+		}
+
 		if imgui.Button("Save level") {
 			filename, err := dialog.File().Filter("JSON files", "json").Title("Save Level").Save()
 			if err != nil {
@@ -128,6 +184,32 @@ func (gui *Gui) Render() {
 			}
 		}
 
+		if gui.game.Recording() {
+			if imgui.Button("Stop recording") {
+				gui.game.StopRecording()
+			}
+		} else if imgui.Button("Record session") {
+			filename, err := dialog.File().Filter("Replay files", "famreplay").Title("Record Session").Save()
+			if err != nil {
+				log.Println(err)
+			} else if err = gui.game.StartRecording(filename); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if imgui.Button("Watch replay") {
+			filename, err := dialog.File().Filter("Replay files", "famreplay").Title("Watch Replay").Load()
+			if err != nil {
+				log.Println(err)
+			} else if replay, err := LoadReplay(filename); err != nil {
+				log.Println(err)
+			} else {
+				gui.game.StartReplay(replay)
+				gui.game.resumeState = stateActive
+				gui.game.unpause()
+			}
+		}
+
 		// LMB, RMB action
 		//items := []string{actionBanana, actionBomb}
 
@@ -148,8 +230,102 @@ func (gui *Gui) Render() {
 			gui.game.reset()
 		}
 
-		imgui.Checkbox("Chase Banana", &gui.game.chaseBananaMode)
-		imgui.Checkbox("Random Bombs", &gui.game.randomBombMode)
+		if imgui.BeginCombo("Game mode", gui.game.Mode().Name()) {
+			for _, mode := range GameModes {
+				if imgui.SelectableV(mode.Name(), mode == gui.game.Mode(), 0, imgui.Vec2{}) {
+					gui.game.SetMode(mode)
+				}
+			}
+			imgui.EndCombo()
+		}
+		if gui.game.Recording() || gui.game.Replaying() {
+			// rounds would end at a different time than the recording has
+			imgui.Text(fmt.Sprintf("Round length %.0f seconds", gui.game.RoundLength))
+		} else {
+			length := float32(gui.game.RoundLength)
+			if imgui.DragFloatV("Round length", &length, 1, 10, 600, "%.0f seconds", 1) {
+				gui.game.RoundLength = float64(length)
+			}
+		}
+		if imgui.Button("New round") {
+			gui.game.nextRound()
+		}
+
+		if gui.seed == "" {
+			gui.seed = strconv.FormatInt(gui.game.seed, 10)
+		}
+		imgui.InputText("Seed", &gui.seed)
+		if imgui.Button("Reset with seed") {
+			seed, err := strconv.ParseInt(gui.seed, 10, 64)
+			if err != nil {
+				log.Println(err)
+			} else {
+				log.Println("Seed", seed)
+				gui.game.Reseed(seed)
+			}
+		}
+
+		if imgui.Button("Controls") {
+			gui.showControls = true
+		}
+		imgui.SameLine()
+		if imgui.Button("Load controller mappings") {
+			filename, err := dialog.File().Filter("SDL gamecontrollerdb", "txt").Title("Load Controller Mappings").Load()
+			if err != nil {
+				log.Println(err)
+			} else if err = gui.game.Gamepads.LoadFile(filename); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if imgui.Button("Particles") {
+			gui.showParticles = true
+		}
+
+		if imgui.BeginCombo("Bot", gui.botBehaviour.String()) {
+			for _, behaviour := range BotBehaviours {
+				if imgui.SelectableV(behaviour.String(), behaviour == gui.botBehaviour, 0, imgui.Vec2{}) {
+					gui.botBehaviour = behaviour
+				}
+			}
+			imgui.EndCombo()
+		}
+		if imgui.Button("Add bot") {
+			gui.game.Do(Action{Kind: ActionAddBot, Bot: gui.botBehaviour})
+		}
+		imgui.SameLine()
+		if imgui.Button("Remove bot") {
+			gui.game.Do(Action{Kind: ActionRemoveBot})
+		}
+
+		if imgui.BeginCombo("Wall material", gui.game.editor.material.String()) {
+			for _, material := range WallMaterials {
+				if imgui.SelectableV(material.String(), material == gui.game.editor.material, 0, imgui.Vec2{}) {
+					gui.game.editor.material = material
+				}
+			}
+			imgui.EndCombo()
+		}
+
+		// the other modes pick their own spawning, so only free play lets
+		// the level's be changed
+		if _, free := gui.game.Mode().(FreePlay); free {
+			if imgui.Checkbox("Chase Banana", &gui.game.chaseBananaMode) {
+				gui.game.level.ChaseBanana = gui.game.chaseBananaMode
+			}
+			if imgui.Checkbox("Random Bombs", &gui.game.randomBombMode) {
+				gui.game.level.RandomBombs = gui.game.randomBombMode
+			}
+		}
+		if imgui.BeginCombo("Split screen", splitScreenNames[gui.game.split.Panes]) {
+			for _, panes := range []int{1, 2, 3, 4} {
+				if imgui.SelectableV(splitScreenNames[panes], panes == gui.game.split.Panes, 0, imgui.Vec2{}) {
+					gui.game.split.Panes = panes
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.Checkbox("Show scores", &gui.game.showHUD)
 		imgui.Checkbox("Render Physics", &gui.game.shouldRenderCp)
 		if imgui.Checkbox("Vsync", &gui.game.vsync) {
 			if gui.game.vsync {
@@ -168,6 +344,13 @@ func (gui *Gui) Render() {
 		imgui.End()
 	}
 
+	if gui.showControls {
+		gui.renderControls()
+	}
+	if gui.showParticles {
+		gui.renderParticles()
+	}
+
 	// 3. Show another simple window.
 	if gui.showAnotherWindow {
 		// Pass a pointer to our bool variable (the window will have a closing button that will clear the bool when clicked)
@@ -183,3 +366,343 @@ func (gui *Gui) Render() {
 	imgui.Render()
 	gui.renderer.Render(p.DisplaySize(), p.FramebufferSize(), imgui.RenderedDrawData())
 }
+
+// keyChange passes keys on to the gui, since the game's key callback replaces
+// its own. It reports whether the gui is taking them to type with.
+func (gui *Gui) keyChange(key Key, action glfw.Action) bool {
+	if gui.game.state != statePause && gui.game.state != stateJoin {
+		return false
+	}
+	io := imgui.CurrentIO()
+	if action == glfw.Press {
+		io.KeyPress(int(key))
+	} else if action == glfw.Release {
+		io.KeyRelease(int(key))
+	}
+	return gui.typing()
+}
+
+// typing reports whether a text box has the keyboard.
+func (gui *Gui) typing() bool {
+	return imgui.CurrentIO().WantTextInput()
+}
+
+// renderJoin shows who has joined the join screen. Keyboard players type
+// their names here, and the mouse can change anyone's seat.
+func (gui *Gui) renderJoin() {
+	join := &gui.game.joining
+	imgui.Begin("Join")
+	imgui.Text("Press jump to join. Left and right pick a colour, jump again when ready.")
+	imgui.Text(fmt.Sprintf("On a gamepad %v picks a name and %v leaves.", joinNameButton, joinLeaveButton))
+	imgui.Separator()
+	for i, seat := range join.Seats {
+		imgui.PushID(strconv.Itoa(i))
+		color := eng.Colors[seat.Color]
+		imgui.PushStyleColor(imgui.StyleColorButton, imgui.Vec4{X: color.X(), Y: color.Y(), Z: color.Z(), W: 1})
+		if imgui.ButtonV("##color", imgui.Vec2{X: 20, Y: 20}) {
+			seat.Color = join.freeColor(seat.Color+1, 1)
+		}
+		imgui.PopStyleColor()
+		imgui.SameLine()
+		imgui.PushItemWidth(150)
+		imgui.InputText("##name", &seat.Name)
+		imgui.PopItemWidth()
+		imgui.SameLine()
+		if imgui.BeginComboV("##names", "", imgui.ComboFlagNoPreview) {
+			for _, name := range gui.game.names() {
+				if imgui.SelectableV(name, name == seat.Name, 0, imgui.Vec2{}) {
+					seat.Name = name
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.SameLine()
+		imgui.Checkbox("Ready", &seat.Ready)
+		imgui.SameLine()
+		leave := imgui.Button("Leave")
+		imgui.SameLine()
+		imgui.Text(seat.key)
+		imgui.PopID()
+		if leave {
+			join.leave(seat)
+			break
+		}
+	}
+	imgui.Separator()
+	if len(join.Seats) > 0 && imgui.Button("Start") {
+		gui.game.startJoined()
+	}
+	imgui.End()
+}
+
+// renderControls shows the bindings of each action. Clicking a binding
+// removes it and "+" binds the next key, gamepad button or axis pressed.
+func (gui *Gui) renderControls() {
+	imgui.BeginV("Controls", &gui.showControls, 0)
+	if gui.rebindError != "" {
+		imgui.Text(gui.rebindError)
+	}
+	for _, key := range gui.game.KeyClashes() {
+		imgui.Text(fmt.Sprintf("%v is bound to the game and a keyboard player, and stops working for the game once they join", keyName(key)))
+	}
+	imgui.Text("Gamepads and game")
+	for _, action := range InputActions {
+		gui.renderBinding("pad", gui.game.Bindings, action, !playerAction(action), true)
+	}
+	for i, set := range gui.game.KeySets {
+		imgui.Separator()
+		imgui.Text(fmt.Sprintf("Keyboard player %d: %v", i+1, set.Name))
+		for _, action := range InputActions {
+			if playerAction(action) {
+				gui.renderBinding(set.Name, set.Bindings, action, true, false)
+			}
+		}
+	}
+	imgui.Separator()
+	if imgui.Button("Reset to defaults") {
+		gui.game.Controls = DefaultControls()
+		gui.saveControls()
+	}
+	imgui.End()
+
+	if !gui.showControls {
+		gui.rebinding = ""
+	}
+	if gui.rebinding != "" && gui.rebindPads {
+		gui.pollRebind()
+	}
+}
+
+func (gui *Gui) renderBinding(id string, bindings Bindings, action InputAction, keys, pads bool) {
+	imgui.PushID(id + string(action))
+	imgui.Text(action.String())
+	for i, bind := range bindings[action] {
+		imgui.SameLine()
+		if imgui.Button(fmt.Sprintf("%v##%d", bind, i)) {
+			bindings.Remove(action, i)
+			gui.saveControls()
+			break
+		}
+	}
+	imgui.SameLine()
+	if gui.rebinding == action && gui.rebindID == id {
+		switch {
+		case keys && pads:
+			imgui.Text("press a key or button, Esc cancels")
+		case keys:
+			imgui.Text("press a key, Esc cancels")
+		default:
+			imgui.Text("press a button or move a stick, Esc cancels")
+		}
+	} else if imgui.Button("+") {
+		gui.startRebind(id, bindings, action, keys, pads)
+	}
+	imgui.PopID()
+}
+
+func (gui *Gui) startRebind(id string, bindings Bindings, action InputAction, keys, pads bool) {
+	gui.rebinding = action
+	gui.rebindID = id
+	gui.rebindTarget = bindings
+	gui.rebindKeys = keys
+	gui.rebindPads = pads
+	gui.rebindError = ""
+	gui.rebindRest = map[JoystickInput]GamepadState{}
+	for joy := glfw.Joystick1; joy <= glfw.JoystickLast; joy++ {
+		if joy.Present() {
+			gui.rebindRest[JoystickInput(joy)] = gui.game.Gamepads.State(JoystickInput(joy))
+		}
+	}
+}
+
+// bindKey is called by the key callback while rebinding.
+func (gui *Gui) bindKey(key Key) {
+	if key == KeyEscape {
+		gui.rebinding = ""
+	} else if gui.rebindKeys {
+		if err := gui.keyClash(key); err != "" {
+			gui.rebindError = err
+			gui.rebinding = ""
+			return
+		}
+		gui.bind(KeyBinding(key))
+	}
+}
+
+// keyClash says why key can't be bound to the action being rebound, if it
+// can't: a key can't both act on the whole game and move a keyboard player.
+func (gui *Gui) keyClash(key Key) string {
+	controls := gui.game.Controls
+	if playerAction(gui.rebinding) {
+		if action, ok := controls.gameAction(key); ok {
+			return fmt.Sprintf("%v is already bound to %v", keyName(key), action)
+		}
+	} else if set, ok := controls.keySetWith(key); ok {
+		return fmt.Sprintf("%v is already used by the %v keys", keyName(key), set.Name)
+	}
+	return ""
+}
+
+// pollRebind binds the first gamepad button pressed or axis moved.
+func (gui *Gui) pollRebind() {
+	for joy, rest := range gui.rebindRest {
+		pad := gui.game.Gamepads.State(joy)
+		for i, down := range pad.Buttons {
+			if down && !rest.Buttons[i] {
+				gui.bind(ButtonBinding(GamepadButton(i)))
+				return
+			}
+		}
+		for i, v := range pad.Axes {
+			if math.Abs(v-rest.Axes[i]) > 0.5 && math.Abs(v) > 0.5 {
+				gui.bind(AxisBinding(GamepadAxis(i), math.Copysign(1, v)))
+				return
+			}
+		}
+	}
+}
+
+func (gui *Gui) bind(bind Binding) {
+	gui.rebindTarget.Add(gui.rebinding, bind)
+	gui.rebinding = ""
+	gui.saveControls()
+}
+
+func (gui *Gui) saveControls() {
+	if err := SaveControls(bindingsFile, gui.game.Controls); err != nil {
+		log.Println(err)
+	}
+}
+
+// renderParticles tweaks the particle emitters. Changes show up straight away,
+// even on particles already flying, and last once saved.
+func (gui *Gui) renderParticles() {
+	imgui.BeginV("Particles", &gui.showParticles, 0)
+	defer imgui.End()
+
+	var names []string
+	for name := range gui.game.emitters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if _, ok := gui.game.emitters[gui.emitter]; !ok && len(names) > 0 {
+		gui.emitter = names[0]
+	}
+	if imgui.BeginCombo("Emitter", gui.emitter) {
+		for _, name := range names {
+			if imgui.SelectableV(name, name == gui.emitter, 0, imgui.Vec2{}) {
+				gui.emitter = name
+			}
+		}
+		imgui.EndCombo()
+	}
+	e := gui.game.emitters[gui.emitter]
+	if e == nil {
+		imgui.Text("No emitters in " + emittersFile)
+		return
+	}
+
+	if imgui.BeginCombo("Texture", e.Texture) {
+		var textures []string
+		for name := range gui.game.textureFiles {
+			textures = append(textures, name)
+		}
+		sort.Strings(textures)
+		for _, name := range textures {
+			if imgui.SelectableV(name, name == e.Texture, 0, imgui.Vec2{}) {
+				gui.game.particleTexture(name)
+				e.Texture = name
+			}
+		}
+		imgui.EndCombo()
+	}
+	if imgui.BeginCombo("Blend", e.Blend.String()) {
+		for _, blend := range eng.BlendModes {
+			if imgui.SelectableV(blend.String(), blend == e.Blend, 0, imgui.Vec2{}) {
+				e.Blend = blend
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	imgui.DragFloatV("Rate", &e.Rate, 1, 0, 1000, "%.0f per second", 1)
+	burst := int32(e.Burst)
+	if imgui.DragIntV("Burst", &burst, 1, 0, 500, "%d") {
+		e.Burst = int(burst)
+	}
+	imgui.DragFloatV("Life min", &e.LifeMin, .01, 0, 10, "%.2f s", 1)
+	imgui.DragFloatV("Life max", &e.LifeMax, .01, 0, 10, "%.2f s", 1)
+	imgui.DragFloatV("Speed min", &e.SpeedMin, 1, 0, 2000, "%.0f", 1)
+	imgui.DragFloatV("Speed max", &e.SpeedMax, 1, 0, 2000, "%.0f", 1)
+	imgui.SliderFloatV("Direction", &e.Direction, -180, 180, "%.0f deg", 1)
+	imgui.SliderFloatV("Spread", &e.Spread, 0, 180, "%.0f deg", 1)
+	imgui.DragFloatV("Gravity x", &e.Gravity[0], 1, -2000, 2000, "%.0f", 1)
+	imgui.DragFloatV("Gravity y", &e.Gravity[1], 1, -2000, 2000, "%.0f", 1)
+	imgui.SliderFloat("Color jitter", &e.ColorJitter, 0, 1)
+
+	imgui.Separator()
+	imgui.Text("Color over life")
+	for i := 0; i < len(e.Colors); i++ {
+		imgui.PushID(fmt.Sprint("color", i))
+		rgb := [3]float32{e.Colors[i][0], e.Colors[i][1], e.Colors[i][2]}
+		if imgui.SliderFloat3("RGB", &rgb, 0, 1) {
+			e.Colors[i] = mgl32.Vec4{rgb[0], rgb[1], rgb[2], e.Colors[i][3]}
+		}
+		imgui.SliderFloat("Alpha", &e.Colors[i][3], 0, 1)
+		if len(e.Colors) > 1 {
+			imgui.SameLine()
+			if imgui.Button("Remove") {
+				e.Colors = append(e.Colors[:i], e.Colors[i+1:]...)
+				i--
+			}
+		}
+		imgui.PopID()
+	}
+	if imgui.Button("Add color") {
+		color := mgl32.Vec4{1, 1, 1, 1}
+		if len(e.Colors) > 0 {
+			color = e.Colors[len(e.Colors)-1]
+		}
+		e.Colors = append(e.Colors, color)
+	}
+
+	imgui.Separator()
+	imgui.Text("Size over life")
+	for i := 0; i < len(e.Sizes); i++ {
+		imgui.PushID(fmt.Sprint("size", i))
+		imgui.DragFloatV("Size", &e.Sizes[i], .5, 0, 500, "%.1f", 1)
+		if len(e.Sizes) > 1 {
+			imgui.SameLine()
+			if imgui.Button("Remove") {
+				e.Sizes = append(e.Sizes[:i], e.Sizes[i+1:]...)
+				i--
+			}
+		}
+		imgui.PopID()
+	}
+	if imgui.Button("Add size") {
+		size := float32(10)
+		if len(e.Sizes) > 0 {
+			size = e.Sizes[len(e.Sizes)-1]
+		}
+		e.Sizes = append(e.Sizes, size)
+	}
+
+	imgui.Separator()
+	if imgui.Button("Try it") {
+		// emitters that only run attached have no burst, show a second's worth
+		n := e.Burst
+		if n == 0 {
+			n = int(e.Rate)
+		}
+		gui.game.ParticleGenerator.EmitN(e, n, eng.V(gui.game.Camera.Position))
+	}
+	imgui.SameLine()
+	if imgui.Button("Save particles") {
+		gui.game.saveEmitters()
+	}
+	imgui.SameLine()
+	if imgui.Button("Reload") {
+		gui.game.loadEmitters()
+	}
+}
@@ -3,7 +3,7 @@ package gui
 import (
 	"math"
 
-	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/inkyblackness/imgui-go"
 )
 
@@ -166,7 +166,7 @@ func (platform *GLFW) charChange(window *glfw.Window, char rune) {
 
 // ClipboardText returns the current clipboard text, if available.
 func (platform *GLFW) ClipboardText() (string, error) {
-	return platform.window.GetClipboardString()
+	return platform.window.GetClipboardString(), nil
 }
 
 // SetClipboardText sets the text as the current clipboard text.
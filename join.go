@@ -0,0 +1,281 @@
+package fam
+
+import (
+	"log"
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// joinSeat is a controller that joined on the join screen.
+type joinSeat struct {
+	Input InputSource
+	// key is the profile the seat is saved to
+	key   string
+	Name  string
+	Color int // in eng.Colors
+	Ready bool
+}
+
+// JoinScreen comes before a game: each controller presses jump to join,
+// picks a colour with left and right and a name, and presses jump again
+// when ready. The game starts when everyone is.
+type JoinScreen struct {
+	Seats []*joinSeat
+	// down holds what each controller held last tick, so presses fire once
+	down map[InputSource]map[InputAction]bool
+}
+
+// the gamepad buttons that pick the next name and leave, next to jump on A
+const (
+	joinNameButton  = ButtonY
+	joinLeaveButton = ButtonB
+)
+
+// join opens the join screen with the people already playing sat down.
+// Bots stay as they are.
+func (g *Game) join() {
+	if g.state == stateEdit {
+		g.editor.Release(g.World, g.Mouse)
+	}
+	g.StopReplay()
+	g.joining = JoinScreen{down: map[InputSource]map[InputAction]bool{}}
+	for _, p := range g.Players {
+		if key := g.profileKey(p.Input); key != "" {
+			seat := &joinSeat{Input: p.Input, key: key, Name: p.Name, Color: colorIndex(p.Color)}
+			if seat.Color < 0 {
+				seat.Color = g.joining.freeColor(0, 1)
+			}
+			g.joining.Seats = append(g.joining.Seats, seat)
+		}
+	}
+	g.state = stateJoin
+}
+
+// pollJoin reads every gamepad and key set for the join screen.
+func (g *Game) pollJoin() {
+	for joy := glfw.Joystick1; joy <= glfw.JoystickLast; joy++ {
+		if !joy.Present() {
+			continue
+		}
+		input := JoystickInput(joy)
+		pad := g.Gamepads.State(input)
+		g.joinInput(input, func(action InputAction) bool {
+			return g.Bindings.Pressed(action, nil, &pad)
+		}, pad.Buttons[joinNameButton], pad.Buttons[joinLeaveButton])
+	}
+	// keyboard players type their names, so don't read keys as moves meanwhile
+	if !g.gui.typing() {
+		for i, set := range g.KeySets {
+			g.joinInput(KeySetInput(i), func(action InputAction) bool {
+				return set.Bindings.Pressed(action, g.Keys, nil)
+			}, false, false)
+		}
+	}
+
+	if len(g.joining.Seats) == 0 {
+		return
+	}
+	for _, seat := range g.joining.Seats {
+		if !seat.Ready {
+			return
+		}
+	}
+	g.startJoined()
+}
+
+// joinInput acts on what input pressed since last tick.
+func (g *Game) joinInput(input InputSource, pressed func(InputAction) bool, name, leave bool) {
+	down := g.joining.down[input]
+	if down == nil {
+		down = map[InputAction]bool{}
+		g.joining.down[input] = down
+	}
+	// the name and leave buttons aren't actions, so they get made-up ones here
+	const nameAction, leaveAction InputAction = "name", "leave"
+	press := func(action InputAction, held bool) bool {
+		was := down[action]
+		down[action] = held
+		return held && !was
+	}
+	jump := press(InputJump, pressed(InputJump))
+	left := press(InputMoveLeft, pressed(InputMoveLeft))
+	right := press(InputMoveRight, pressed(InputMoveRight))
+	name = press(nameAction, name)
+	leave = press(leaveAction, leave)
+
+	seat := g.joining.seat(input)
+	if seat == nil {
+		if jump {
+			g.sitDown(input)
+		}
+		return
+	}
+	switch {
+	case leave:
+		g.joining.leave(seat)
+	case jump:
+		seat.Ready = !seat.Ready
+	case seat.Ready:
+		// ready players have made up their minds
+	case left:
+		seat.Color = g.joining.freeColor(seat.Color-1, -1)
+	case right:
+		seat.Color = g.joining.freeColor(seat.Color+1, 1)
+	case name:
+		seat.Name = g.nextName(seat.Name)
+	}
+}
+
+// sitDown joins input, as they were last time if they have a profile.
+func (g *Game) sitDown(input InputSource) {
+	key := g.profileKey(input)
+	seat := &joinSeat{Input: input, key: key, Color: -1}
+	if profile, ok := g.profiles[key]; ok {
+		seat.Name = profile.Name
+		seat.Color = colorIndex(profile.Color)
+	}
+	if seat.Color < 0 || g.joining.colorTaken(seat.Color) {
+		seat.Color = g.joining.freeColor(seat.Color+1, 1)
+	}
+	if seat.Name == "" {
+		seat.Name = g.freshName()
+	}
+	g.joining.Seats = append(g.joining.Seats, seat)
+}
+
+func (j *JoinScreen) seat(input InputSource) *joinSeat {
+	for _, seat := range j.Seats {
+		if seat.Input == input {
+			return seat
+		}
+	}
+	return nil
+}
+
+func (j *JoinScreen) leave(seat *joinSeat) {
+	for i, s := range j.Seats {
+		if s == seat {
+			j.Seats = append(j.Seats[:i], j.Seats[i+1:]...)
+			return
+		}
+	}
+}
+
+// colorTaken reports whether a seat has the colour.
+func (j *JoinScreen) colorTaken(color int) bool {
+	for _, s := range j.Seats {
+		if s.Color == color {
+			return true
+		}
+	}
+	return false
+}
+
+// freeColor is the first colour from start, going in direction step, that
+// no one has. When there are more players than colours they have to share.
+func (j *JoinScreen) freeColor(start, step int) int {
+	n := len(eng.Colors)
+	start = ((start % n) + n) % n
+	for i := 0; i < n; i++ {
+		color := ((start+i*step)%n + n) % n
+		if !j.colorTaken(color) {
+			return color
+		}
+	}
+	return start
+}
+
+// nextName is the name after name in the list of names to pick from,
+// skipping those already taken.
+func (g *Game) nextName(name string) string {
+	names := g.names()
+	start := 0
+	for i, n := range names {
+		if n == name {
+			start = i + 1
+			break
+		}
+	}
+next:
+	for i := 0; i < len(names); i++ {
+		candidate := names[(start+i)%len(names)]
+		for _, seat := range g.joining.Seats {
+			if seat.Name == candidate {
+				continue next
+			}
+		}
+		return candidate
+	}
+	return name
+}
+
+// freshName is a name for a newcomer: the first of playerNames that no one
+// has, here or in a profile.
+func (g *Game) freshName() string {
+	taken := map[string]bool{}
+	for _, profile := range g.profiles {
+		taken[profile.Name] = true
+	}
+	for _, seat := range g.joining.Seats {
+		taken[seat.Name] = true
+	}
+	for _, name := range playerNames {
+		if !taken[name] {
+			return name
+		}
+	}
+	return g.nextName("")
+}
+
+// names are the names to pick from: those picked before, then playerNames.
+func (g *Game) names() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, profile := range g.profiles {
+		if profile.Name != "" && !seen[profile.Name] {
+			seen[profile.Name] = true
+			names = append(names, profile.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range playerNames {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// startJoined starts a new round with the seated players, and remembers
+// their profiles for next time.
+func (g *Game) startJoined() {
+	var players []*Player
+	for _, seat := range g.joining.Seats {
+		p := NewPlayer(cp.Vector{}, playerRadius, g.World)
+		p.Input = seat.Input
+		p.Name = seat.Name
+		p.Color = eng.Colors[seat.Color]
+		p.Joined = true
+		players = append(players, p)
+		if seat.key != "" {
+			g.profiles[seat.key] = Profile{Name: seat.Name, Color: p.Color}
+		}
+	}
+	for _, p := range g.Players {
+		if _, ok := p.Input.(*Bot); ok {
+			players = append(players, p)
+		}
+	}
+	g.Players = players
+	// a recording can't follow the players changing
+	g.StopRecording()
+	g.nextRound()
+
+	if err := SaveProfiles(profilesFile, g.profiles); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,92 @@
+package fam
+
+// Key is a keyboard key. Keys are numbered the way GLFW numbers them, so the
+// window hands its keys straight to the world and bindings files written
+// with GLFW codes still load, but the world itself doesn't need GLFW.
+type Key int
+
+// printable keys are their ASCII character
+const (
+	KeySpace        Key = 32
+	KeyApostrophe   Key = 39
+	KeySemicolon    Key = 59
+	KeyLeftBracket  Key = 91
+	KeyRightBracket Key = 93
+	KeyGraveAccent  Key = 96
+)
+
+const (
+	KeyA Key = 65 + iota
+	KeyB
+	KeyC
+	KeyD
+	KeyE
+	KeyF
+	KeyG
+	KeyH
+	KeyI
+	KeyJ
+	KeyK
+	KeyL
+	KeyM
+	KeyN
+	KeyO
+	KeyP
+	KeyQ
+	KeyR
+	KeyS
+	KeyT
+	KeyU
+	KeyV
+	KeyW
+	KeyX
+	KeyY
+	KeyZ
+)
+
+const (
+	KeyEscape Key = 256 + iota
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyInsert
+	KeyDelete
+	KeyRight
+	KeyLeft
+	KeyDown
+	KeyUp
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+)
+
+const (
+	KeyF1  Key = 290
+	KeyF25 Key = 314
+)
+
+const (
+	KeyKP0 Key = 320 + iota
+	KeyKP1
+	KeyKP2
+	KeyKP3
+	KeyKP4
+	KeyKP5
+	KeyKP6
+	KeyKP7
+	KeyKP8
+	KeyKP9
+)
+
+const KeyKPEnter Key = 335
+
+const (
+	KeyLeftShift Key = 340 + iota
+	KeyLeftControl
+	KeyLeftAlt
+	KeyLeftSuper
+	KeyRightShift
+	KeyRightControl
+	KeyRightAlt
+)
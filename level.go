@@ -0,0 +1,188 @@
+package fam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jakecoffman/cp/v2"
+)
+
+// levelVersion is the version saveLevel writes. Version 0 is the original
+// format: a bare JSON array of {A, B} wall segments.
+const levelVersion = 2
+
+const initialLevel = "assets/levels/initial.json"
+
+// Level is the document a level is saved as.
+type Level struct {
+	Version int
+
+	Name   string
+	Author string
+
+	// Background is the name of the texture drawn behind the level.
+	Background string
+	Gravity    cp.Vector
+	// Width and Height are the size of the world, which can be bigger than
+	// the screen. Version 1 levels and older are one screen big.
+	Width, Height float64
+
+	ChaseBanana bool
+	RandomBombs bool
+
+	// Spawns are where players appear, handed out in turn. Players spawn at
+	// the world centre when there are none.
+	Spawns []cp.Vector
+
+	Walls     []LevelWall
+	Platforms []LevelPlatform `json:",omitempty"`
+	// Blocks are the solid squares of the level.
+	Blocks  []cp.BB     `json:",omitempty"`
+	Ropes   []LevelRope `json:",omitempty"`
+	Bumpers []cp.Vector `json:",omitempty"`
+	// Springs are the bases of the spring pads.
+	Springs []cp.Vector `json:",omitempty"`
+	Bananas []LevelBanana
+	Bombs   []cp.Vector
+	Crates  []LevelCrate `json:",omitempty"`
+}
+
+type LevelWall struct {
+	A, B       cp.Vector
+	Friction   float64
+	Elasticity float64
+	Material   WallMaterial `json:",omitempty"`
+}
+
+// UnmarshalJSON gives walls the friction and bounce of their material when
+// the file doesn't set them, which covers every wall of a version 0 level.
+func (lw *LevelWall) UnmarshalJSON(data []byte) error {
+	type plain LevelWall
+	var wall plain
+	if err := json.Unmarshal(data, &wall); err != nil {
+		return err
+	}
+	var set struct {
+		Friction, Elasticity *float64
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	props := wall.Material.properties()
+	if set.Friction == nil {
+		wall.Friction = props.friction
+	}
+	if set.Elasticity == nil {
+		wall.Elasticity = props.elasticity
+	}
+	*lw = LevelWall(wall)
+	return nil
+}
+
+// LevelPlatform is a moving platform. It starts at the first point of Path
+// turned to Angle, and Length long.
+type LevelPlatform struct {
+	Path     []cp.Vector
+	Length   float64
+	Angle    float64
+	Motion   PlatformMotion
+	Speed    float64
+	Material WallMaterial `json:",omitempty"`
+}
+
+type LevelBanana struct {
+	Pos cp.Vector
+	// Fruit is banana, strawberry or blueberry. Empty picks one at random.
+	Fruit string
+}
+
+// LevelRope hangs Length down from Anchor, and is a chain when Chain is set.
+type LevelRope struct {
+	Anchor cp.Vector
+	Length float64
+	Chain  bool `json:",omitempty"`
+}
+
+type LevelCrate struct {
+	Pos   cp.Vector
+	Angle float64
+	Sides int
+	Size  float64
+}
+
+// UnmarshalJSON makes crates that don't say what shape or size they are
+// boxes of the usual size.
+func (lc *LevelCrate) UnmarshalJSON(data []byte) error {
+	type plain LevelCrate
+	var crate plain
+	if err := json.Unmarshal(data, &crate); err != nil {
+		return err
+	}
+	if crate.Sides < 3 {
+		crate.Sides = 4
+	}
+	if crate.Size <= 0 {
+		crate.Size = crateSize
+	}
+	*lc = LevelCrate(crate)
+	return nil
+}
+
+// NewLevel returns an empty level with the default settings.
+func NewLevel() *Level {
+	return &Level{
+		Version:    levelVersion,
+		Background: "background",
+		Gravity:    cp.Vector{0, Gravity},
+		Width:      worldWidth,
+		Height:     worldHeight,
+	}
+}
+
+// ReadLevel loads a level file, migrating older versions to the current one.
+func ReadLevel(filename string) (*Level, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	level := NewLevel()
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		// version 0, walls only
+		if err = json.Unmarshal(data, &level.Walls); err != nil {
+			return nil, err
+		}
+		return level, nil
+	}
+
+	level.Version = 0
+	if err = json.Unmarshal(data, level); err != nil {
+		return nil, err
+	}
+	if level.Version > levelVersion {
+		return nil, fmt.Errorf("%v: level version %v is newer than this game supports", filename, level.Version)
+	}
+	if level.Width <= 0 || level.Height <= 0 {
+		level.Width, level.Height = worldWidth, worldHeight
+	}
+	level.Version = levelVersion
+	return level, nil
+}
+
+// WriteLevel saves a level in the current format.
+func WriteLevel(filename string, level *Level) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	level.Version = levelVersion
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(level); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
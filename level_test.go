@@ -0,0 +1,38 @@
+package fam
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadLevelWallMaterials(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "materials.json")
+	err := os.WriteFile(filename, []byte(`{"Version": 1, "Walls": [
+		{"A": {"X": 0, "Y": 0}, "B": {"X": 100, "Y": 0}},
+		{"A": {"X": 0, "Y": 0}, "B": {"X": 100, "Y": 0}, "Material": "ice"},
+		{"A": {"X": 0, "Y": 0}, "B": {"X": 100, "Y": 0}, "Material": "sticky"},
+		{"A": {"X": 0, "Y": 0}, "B": {"X": 100, "Y": 0}, "Material": "ice", "Friction": 5, "Elasticity": 0}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, err := ReadLevel(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ friction, elasticity float64 }{
+		{wallFriction, wallElasticity},
+		{MaterialIce.properties().friction, MaterialIce.properties().elasticity},
+		{MaterialSticky.properties().friction, MaterialSticky.properties().elasticity},
+		{5, 0},
+	}
+	if len(level.Walls) != len(want) {
+		t.Fatalf("read %d walls, want %d", len(level.Walls), len(want))
+	}
+	for i, w := range want {
+		if got := level.Walls[i]; got.Friction != w.friction || got.Elasticity != w.elasticity {
+			t.Errorf("wall %d has friction %v and elasticity %v, want %v and %v", i, got.Friction, got.Elasticity, w.friction, w.elasticity)
+		}
+	}
+}
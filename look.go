@@ -0,0 +1,126 @@
+package fam
+
+import (
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// the player's face and body animations
+var (
+	idleFace = &eng.Animation{
+		Frames: []eng.Frame{{Texture: "face", Duration: 1}},
+		Loop:   eng.LoopRepeat,
+	}
+	blinkFace = &eng.Animation{
+		Frames: []eng.Frame{{Texture: "face_blink", Duration: .12}},
+	}
+	happyFace = &eng.Animation{
+		Frames: []eng.Frame{{Texture: "face_happy", Duration: .8}},
+	}
+	// dizzyFace wobbles side to side
+	dizzyFace = &eng.Animation{
+		Frames: []eng.Frame{
+			{Texture: "face_dizzy", Duration: .15, Rotate: .2},
+			{Texture: "face_dizzy", Duration: .15, Rotate: -.2},
+			{Texture: "face_dizzy", Duration: .15, Rotate: .2},
+			{Texture: "face_dizzy", Duration: .15, Rotate: -.2},
+			{Texture: "face_dizzy", Duration: .15, Rotate: .15},
+			{Texture: "face_dizzy", Duration: .15, Rotate: -.15},
+			{Texture: "face_dizzy", Duration: .15, Rotate: .1},
+			{Texture: "face_dizzy", Duration: .15, Rotate: -.1},
+			{Texture: "face_dizzy", Duration: .3},
+		},
+	}
+
+	restBody = &eng.Animation{
+		Frames: []eng.Frame{{Duration: 1}},
+		Loop:   eng.LoopRepeat,
+	}
+	// jumpBody stretches tall and holds it until the player comes down
+	jumpBody = &eng.Animation{
+		Frames: []eng.Frame{
+			{Duration: .08, Scale: mgl32.Vec2{.8, 1.25}},
+			{Duration: .15, Scale: mgl32.Vec2{.9, 1.1}},
+		},
+	}
+	// landBody squashes flat and springs back with a little overshoot
+	landBody = &eng.Animation{
+		Frames: []eng.Frame{
+			{Duration: .05, Scale: mgl32.Vec2{1.35, .7}},
+			{Duration: .06, Scale: mgl32.Vec2{1.15, .88}},
+			{Duration: .06, Scale: mgl32.Vec2{.95, 1.05}},
+			{Duration: .04, Scale: mgl32.Vec2{1, 1}},
+		},
+	}
+)
+
+// seconds between blinks, picked at random in this range
+const (
+	blinkMin = 2.0
+	blinkMax = 5.0
+)
+
+// playerLook is how a player looks: the face shows how they feel and the body
+// squashes and stretches as they move. It's only for show, so it lives
+// outside the simulation and uses its own randomness.
+type playerLook struct {
+	face, body eng.Animator
+	// nextBlink counts down to the next blink
+	nextBlink float64
+}
+
+// eat, bombed, land and jump are the events a player's look reacts to.
+func (l *playerLook) eat() {
+	if l.face.Animation != dizzyFace {
+		l.face.Restart(happyFace)
+	}
+}
+
+func (l *playerLook) bombed() {
+	l.face.Play(dizzyFace)
+}
+
+func (l *playerLook) land() {
+	l.body.Restart(landBody)
+}
+
+func (l *playerLook) jump() {
+	l.body.Restart(jumpBody)
+}
+
+func (l *playerLook) update(p *Player, dt float64) {
+	l.face.Update(dt)
+	l.body.Update(dt)
+
+	// faces go back to idle when they're done, and idle faces blink now and then
+	switch {
+	case l.face.Animation == nil || l.face.Done():
+		l.face.Play(idleFace)
+	case l.face.Animation == idleFace:
+		l.nextBlink -= dt
+		if l.nextBlink <= 0 {
+			l.nextBlink = blinkMin + rand.Float64()*(blinkMax-blinkMin)
+			l.face.Restart(blinkFace)
+		}
+	}
+
+	// the stretch from a jump holds until the player is back on the ground.
+	// They're still touching it as the jump starts, so wait until they fall.
+	landed := p.grounded && p.Velocity().Y >= 0
+	switch {
+	case l.body.Animation == jumpBody && landed, l.body.Animation == nil, l.body.Animation != jumpBody && l.body.Done():
+		l.body.Play(restBody)
+	}
+}
+
+// frame is the texture, size and rotation to draw the player with.
+func (l *playerLook) frame(size mgl32.Vec2, rotate float64) (string, mgl32.Vec2, float64) {
+	face, body := l.face.Frame(), l.body.Frame()
+	texture := face.Texture
+	if texture == "" {
+		texture = "face"
+	}
+	return texture, body.Size(size), rotate + face.Rotate + body.Rotate
+}
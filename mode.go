@@ -0,0 +1,211 @@
+package fam
+
+import "fmt"
+
+// GameMode is the rules of a round: how it starts, what happens as it goes
+// and who wins. Modes run inside the simulation, so replays play out the same.
+type GameMode interface {
+	Name() string
+	// Setup is called as each round starts, once the world has reset.
+	Setup(w *World)
+	// Update applies the mode's rules, once per tick.
+	Update(w *World, dt float64)
+	// Winners reports whether the round is over and who won it. Nobody
+	// winning is fine, like when the fruit runs out before anyone eats.
+	Winners(w *World) (winners []*Player, over bool)
+	// Teardown undoes Setup when the world switches to another mode.
+	Teardown(w *World)
+}
+
+// GameModes are the modes the pause menu offers, free play first.
+var GameModes = []GameMode{
+	FreePlay{},
+	Biggest{},
+	FirstTo{Fruit: 10},
+	LastUnbombed{},
+}
+
+// gameMode looks a mode up by name, falling back to free play.
+func gameMode(name string) GameMode {
+	for _, mode := range GameModes {
+		if mode.Name() == name {
+			return mode
+		}
+	}
+	return GameModes[0]
+}
+
+// how long rounds are unless the pause menu says otherwise, in seconds
+const defaultRoundLength = 60
+
+// FreePlay is the sandbox: the level's own spawning, no time limit, and the
+// round is over once the level's fruit is all eaten.
+type FreePlay struct{}
+
+func (FreePlay) Name() string { return "Free play" }
+
+func (FreePlay) Setup(w *World) {}
+
+func (FreePlay) Update(w *World, dt float64) {}
+
+func (FreePlay) Winners(w *World) ([]*Player, bool) {
+	if w.chaseBananaMode || len(w.level.Bananas) == 0 || len(w.Bananas) > 0 {
+		return nil, false
+	}
+	return mostFruit(w.Players), true
+}
+
+func (FreePlay) Teardown(w *World) {}
+
+// Biggest keeps fruit and bombs coming until time is up, and whoever is
+// biggest then wins.
+type Biggest struct{}
+
+func (Biggest) Name() string { return "Biggest wins" }
+
+func (Biggest) Setup(w *World) {
+	w.chaseBananaMode = true
+	w.randomBombMode = true
+}
+
+func (Biggest) Update(w *World, dt float64) {}
+
+func (Biggest) Winners(w *World) ([]*Player, bool) {
+	if !w.timeUp() {
+		return nil, false
+	}
+	var winners []*Player
+	var biggest float64
+	for _, p := range w.Players {
+		switch r := p.Circle.Radius(); {
+		case r > biggest:
+			winners, biggest = []*Player{p}, r
+		case r == biggest:
+			winners = append(winners, p)
+		}
+	}
+	return winners, true
+}
+
+func (Biggest) Teardown(w *World) {
+	w.chaseBananaMode = w.level.ChaseBanana
+	w.randomBombMode = w.level.RandomBombs
+}
+
+// FirstTo is a race to eat Fruit fruit. If time runs out first, whoever ate
+// the most wins.
+type FirstTo struct {
+	Fruit int
+}
+
+func (m FirstTo) Name() string { return fmt.Sprintf("First to eat %d", m.Fruit) }
+
+func (FirstTo) Setup(w *World) {
+	w.chaseBananaMode = true
+}
+
+func (FirstTo) Update(w *World, dt float64) {}
+
+func (m FirstTo) Winners(w *World) ([]*Player, bool) {
+	var winners []*Player
+	for _, p := range w.Players {
+		if p.Stats.Eaten() >= m.Fruit {
+			winners = append(winners, p)
+		}
+	}
+	if len(winners) > 0 {
+		return winners, true
+	}
+	if w.timeUp() {
+		return mostFruit(w.Players), true
+	}
+	return nil, false
+}
+
+func (FirstTo) Teardown(w *World) {
+	w.chaseBananaMode = w.level.ChaseBanana
+}
+
+// LastUnbombed rains bombs, and anyone caught in one is out: they keep
+// bouncing around, greyed out, but can't win. The last one left wins, or
+// everyone left when time runs out.
+type LastUnbombed struct{}
+
+func (LastUnbombed) Name() string { return "Last un-bombed" }
+
+func (LastUnbombed) Setup(w *World) {
+	w.randomBombMode = true
+}
+
+func (LastUnbombed) Update(w *World, dt float64) {
+	for _, p := range w.Players {
+		if p.Stats.Bombed > 0 {
+			p.out = true
+		}
+	}
+}
+
+func (LastUnbombed) Winners(w *World) ([]*Player, bool) {
+	var left []*Player
+	for _, p := range w.Players {
+		if !p.out {
+			left = append(left, p)
+		}
+	}
+	// playing alone, the round lasts until the player is bombed
+	last := 1
+	if len(w.Players) < 2 {
+		last = 0
+	}
+	if len(left) <= last || w.timeUp() {
+		return left, true
+	}
+	return nil, false
+}
+
+func (LastUnbombed) Teardown(w *World) {
+	w.randomBombMode = w.level.RandomBombs
+	for _, p := range w.Players {
+		p.out = false
+	}
+}
+
+// mostFruit is whoever ate the most, nobody if no one ate anything.
+func mostFruit(players []*Player) []*Player {
+	var winners []*Player
+	most := 1
+	for _, p := range players {
+		switch eaten := p.Stats.Eaten(); {
+		case eaten > most:
+			winners, most = []*Player{p}, eaten
+		case eaten == most:
+			winners = append(winners, p)
+		}
+	}
+	return winners
+}
+
+// SetMode switches the rules and starts a round under them.
+func (w *World) SetMode(mode GameMode) {
+	w.mode.Teardown(w)
+	w.mode = mode
+	w.reset()
+}
+
+func (w *World) Mode() GameMode {
+	return w.mode
+}
+
+// Winners reports whether the round is over and who won.
+func (w *World) Winners() ([]*Player, bool) {
+	return w.mode.Winners(w)
+}
+
+// TimeLeft is how long the round has to go, in seconds.
+func (w *World) TimeLeft() float64 {
+	return w.RoundLength - w.roundTime
+}
+
+func (w *World) timeUp() bool {
+	return w.RoundLength > 0 && w.roundTime >= w.RoundLength
+}
@@ -0,0 +1,197 @@
+package fam
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// PlatformMotion is how a platform moves.
+type PlatformMotion int
+
+const (
+	// MotionPingPong goes along the path and back again.
+	MotionPingPong PlatformMotion = iota
+	// MotionLoop goes along the path and straight back to its start.
+	MotionLoop
+	// MotionSpin turns around the first point of the path.
+	MotionSpin
+)
+
+var platformMotionNames = []string{"pingpong", "loop", "spin"}
+
+func (m PlatformMotion) String() string {
+	if m < 0 || int(m) >= len(platformMotionNames) {
+		return fmt.Sprintf("motion %d", int(m))
+	}
+	return platformMotionNames[m]
+}
+
+func (m PlatformMotion) MarshalText() ([]byte, error) {
+	if m < 0 || int(m) >= len(platformMotionNames) {
+		return nil, fmt.Errorf("unknown platform motion %d", int(m))
+	}
+	return []byte(platformMotionNames[m]), nil
+}
+
+func (m *PlatformMotion) UnmarshalText(text []byte) error {
+	for i, name := range platformMotionNames {
+		if name == string(text) {
+			*m = PlatformMotion(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown platform motion %q", text)
+}
+
+const (
+	// how fast new platforms move, in pixels a second, and spin, in radians
+	// a second
+	platformSpeed = 150.0
+	platformSpin  = 1.0
+)
+
+var (
+	platformPathColor     = eng.FColor{.3, .8, 1, .6}
+	platformWaypointColor = eng.FColor{.3, .8, 1, 1}
+)
+
+// MovingPlatform is a wall on a kinematic body that follows a path of waypoints or
+// spins, carrying whatever rides it.
+type MovingPlatform struct {
+	*Wall
+
+	// Path is where the platform's middle goes, starting at the first point.
+	// Spinning platforms turn around it.
+	Path   []cp.Vector
+	Angle  float64
+	Motion PlatformMotion
+	// Speed is pixels a second along the path, or radians a second spinning.
+	Speed float64
+
+	// next is the waypoint being headed for, step which way along the path
+	next, step int
+}
+
+// NewMovingPlatform makes a platform from a to b, which starts out going nowhere.
+// Like walls, it isn't added to the space.
+func NewMovingPlatform(a, b cp.Vector) *MovingPlatform {
+	mid := a.Lerp(b, .5)
+	diff := b.Sub(a)
+	return newPlatform(diff.Length(), math.Atan2(diff.Y, diff.X), []cp.Vector{mid}, MotionPingPong, platformSpeed)
+}
+
+func newPlatform(length, angle float64, path []cp.Vector, motion PlatformMotion, speed float64) *MovingPlatform {
+	body := cp.NewKinematicBody()
+	p := &MovingPlatform{
+		Wall:   newWall(body, cp.Vector{-length / 2, 0}, cp.Vector{length / 2, 0}),
+		Path:   path,
+		Angle:  angle,
+		Motion: motion,
+		Speed:  speed,
+	}
+	p.Reset()
+	return p
+}
+
+// Reset puts the platform back at the start of its path.
+func (p *MovingPlatform) Reset() {
+	body := p.Body()
+	body.SetVelocityVector(cp.Vector{})
+	body.SetAngularVelocity(0)
+	body.SetPosition(p.Path[0])
+	body.SetAngle(p.Angle)
+	p.next, p.step = 0, 1
+	if len(p.Path) > 1 {
+		p.next = 1
+	}
+	p.SetSurfaceV(p.surfaceVelocity())
+	// the space only moves shapes when it steps, which it doesn't in the editor
+	p.Shape.CacheBB()
+}
+
+// Length is how long the platform is from end to end.
+func (p *MovingPlatform) Length() float64 {
+	return p.B().Distance(p.A())
+}
+
+// Update sets the platform's velocity for the coming step, so the space moves
+// it and riders feel it move.
+func (p *MovingPlatform) Update(dt float64) {
+	body := p.Body()
+	if p.Material == MaterialConveyor {
+		// turning platforms turn their conveyors with them
+		p.SetSurfaceV(p.surfaceVelocity())
+	}
+	if p.Motion == MotionSpin {
+		body.SetVelocityVector(cp.Vector{})
+		body.SetAngularVelocity(p.Speed)
+		return
+	}
+	body.SetAngularVelocity(0)
+	if len(p.Path) < 2 || p.Speed <= 0 {
+		body.SetVelocityVector(cp.Vector{})
+		return
+	}
+
+	to := p.Path[p.next].Sub(body.Position())
+	dist := to.Length()
+	if dist > p.Speed*dt {
+		body.SetVelocityVector(to.Mult(p.Speed / dist))
+		return
+	}
+	// arrive this step, then head for the next waypoint
+	body.SetVelocityVector(to.Mult(1 / dt))
+	switch p.Motion {
+	case MotionLoop:
+		p.next = (p.next + 1) % len(p.Path)
+	default:
+		if p.next+p.step < 0 || p.next+p.step >= len(p.Path) {
+			p.step = -p.step
+		}
+		p.next += p.step
+	}
+}
+
+// DrawPath shows where the platform goes: its path and waypoints, or the
+// circle it sweeps when spinning.
+func (p *MovingPlatform) DrawPath(g *Game) {
+	if p.Motion == MotionSpin {
+		g.CPRenderer.DrawCircle(p.Path[0], 0, p.Length()/2, platformPathColor, eng.FColor{})
+	} else {
+		for i := 1; i < len(p.Path); i++ {
+			g.CPRenderer.DrawSegment(p.Path[i-1], p.Path[i], platformPathColor)
+		}
+		if p.Motion == MotionLoop && len(p.Path) > 2 {
+			g.CPRenderer.DrawSegment(p.Path[len(p.Path)-1], p.Path[0], platformPathColor)
+		}
+	}
+	for _, point := range p.Path {
+		g.CPRenderer.DrawDot(5, point, platformWaypointColor)
+	}
+}
+
+// insertPlatform puts a platform into the world at index i of Platforms.
+func (w *World) insertPlatform(i int, p *MovingPlatform) {
+	w.Platforms = append(w.Platforms, nil)
+	copy(w.Platforms[i+1:], w.Platforms[i:])
+	w.Platforms[i] = p
+	w.Space.AddBody(p.Body())
+	w.Space.AddShape(p.Shape)
+}
+
+// removePlatform takes a platform out of the world and returns the index it
+// had in Platforms, or -1 if it wasn't there.
+func (w *World) removePlatform(p *MovingPlatform) int {
+	for i := range w.Platforms {
+		if w.Platforms[i] == p {
+			w.Platforms = append(w.Platforms[:i], w.Platforms[i+1:]...)
+			w.Space.RemoveShape(p.Shape)
+			w.Space.RemoveBody(p.Body())
+			return i
+		}
+	}
+	return -1
+}
@@ -3,42 +3,62 @@ package fam
 import (
 	"math"
 
-	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/jakecoffman/cp/v2"
 	"github.com/jakecoffman/fam/eng"
 )
 
 type Player struct {
+	// Name is drawn over the player, and Color tints them
+	Name  string
 	Color mgl32.Vec3
+	// Joined is set for players from the join screen, who stay through
+	// resets even when they play on the keyboard.
+	Joined bool
 
 	*eng.Object
 	Circle *cp.Circle
 
-	Joystick glfw.Joystick
+	Input InputSource
 
 	remainingBoost          float64
 	grounded, lastJumpState bool
+	// wasGrounded and fallSpeed are from the last tick, to spot landings
+	wasGrounded bool
+	fallSpeed   float64
 
-	// inputX and jumpHeld are polled once per frame in Update and consumed by
-	// the velocity callback (which may run multiple times per Step).
+	// inputX and jumpHeld are set once per tick by the world, from Poll or a
+	// replay, and consumed by the velocity callback (which may run multiple
+	// times per Step).
 	inputX   float64
 	jumpHeld bool
+	// grabHeld is set alongside, and lastGrabState is from the tick before
+	grabHeld, lastGrabState bool
+
+	// swing joins the player to the rope they're hanging from, if any
+	swing     *cp.Constraint
+	swingRope *Rope
+
+	look playerLook
+
+	Stats Stats
+	// out is set when the game mode has knocked the player out of the round
+	out bool
 }
 
-func NewPlayer(pos cp.Vector, radius float64, g *Game) *Player {
+func NewPlayer(pos cp.Vector, radius float64, w *World) *Player {
 	p := &Player{
 		Object: &eng.Object{},
 		Color:  mgl32.Vec3{1, 1, 1},
 	}
-	p.Reset(pos, radius, g)
+	p.Reset(pos, radius, w)
 
 	return p
 }
 
-func (p *Player) Reset(pos cp.Vector, radius float64, g *Game) {
+func (p *Player) Reset(pos cp.Vector, radius float64, w *World) {
 	p.Body = cp.NewBody(1, cp.MomentForCircle(1, radius, radius, cp.Vector{0, 0}))
-	p.Body.SetVelocityUpdateFunc(playerUpdateVelocity(g, p))
+	p.Body.SetVelocityUpdateFunc(playerUpdateVelocity(p))
 
 	p.Shape = cp.NewCircle(p.Body, radius, cp.Vector{0, 0})
 	p.Shape.SetElasticity(0)
@@ -50,47 +70,91 @@ func (p *Player) Reset(pos cp.Vector, radius float64, g *Game) {
 
 	p.Circle = p.Shape.Class.(*cp.Circle)
 	p.Body.SetPosition(pos)
+	p.Stats = newStats(radius)
+	p.out = false
+	p.swing, p.swingRope = nil, nil
 
-	g.Space.AddBody(p.Body)
-	g.Space.AddShape(p.Shape)
+	w.Space.AddBody(p.Body)
+	w.Space.AddShape(p.Shape)
 }
 
-func (p *Player) Update(g *Game, dt float64) {
-	p.Object.Update(g.Space, dt, worldWidth, worldHeight)
-
-	// Poll input once per frame and stash results so that playerUpdateVelocity
-	// (which Chipmunk may invoke multiple times per Step) sees consistent state.
-	const deadzone = 0.15
-	if p.Joystick > -1 {
-		axes := glfw.GetJoystickAxes(p.Joystick)
-		if len(axes) >= 1 {
-			raw := float64(axes[0])
-			if math.Abs(raw) < deadzone {
-				p.inputX = 0
-			} else {
-				// Rescale the range [deadzone, 1] → [0, 1] so the full output
-				// range is available after dead-zone removal.
-				p.inputX = (raw - math.Copysign(deadzone, raw)) / (1 - deadzone)
-			}
-		} else {
-			p.inputX = 0
-		}
+// Poll reads the player's controller. The world stashes the result in
+// inputX/jumpHeld once per tick so that playerUpdateVelocity (which Chipmunk
+// may invoke multiple times per Step) sees consistent state.
+func (p *Player) Poll(w *World) PlayerInput {
+	if p.Input == nil {
+		return PlayerInput{}
+	}
+	return p.Input.Poll(w)
+}
 
-		buttonBytes := glfw.GetJoystickButtons(p.Joystick)
-		if len(buttonBytes) > 0 {
-			p.jumpHeld = glfw.Action(buttonBytes[0]) == glfw.Press
-		} else {
-			p.jumpHeld = false
-		}
-	} else {
-		if g.Keys[glfw.KeyA] || g.Keys[glfw.KeyLeft] {
-			p.inputX = -1
-		} else if g.Keys[glfw.KeyD] || g.Keys[glfw.KeyRight] {
-			p.inputX = 1
+// InputSource is the controller a player is driven by.
+type InputSource interface {
+	Poll(w *World) PlayerInput
+	// Label is drawn over players without a name so they can tell who is who.
+	Label(w *World) string
+}
+
+// JoystickInput drives a player with a gamepad, read through the world's
+// Gamepads mappings and Bindings.
+type JoystickInput int
+
+func (j JoystickInput) Poll(w *World) PlayerInput {
+	pad := w.Gamepads.State(j)
+	return pollBindings(w.Bindings, w, &pad)
+}
+
+func (j JoystickInput) Label(w *World) string {
+	return ""
+}
+
+// KeySetInput drives a player with one of the world's KeySets, so several
+// players can share a keyboard.
+type KeySetInput int
+
+func (k KeySetInput) Poll(w *World) PlayerInput {
+	if int(k) >= len(w.KeySets) {
+		return PlayerInput{}
+	}
+	return pollBindings(w.KeySets[k].Bindings, w, nil)
+}
+
+func (k KeySetInput) Label(w *World) string {
+	if int(k) >= len(w.KeySets) {
+		return ""
+	}
+	return w.KeySets[k].Name
+}
+
+func pollBindings(b Bindings, w *World, pad *GamepadState) PlayerInput {
+	return PlayerInput{
+		X:    b.Value(InputMoveRight, w.Keys, pad) - b.Value(InputMoveLeft, w.Keys, pad),
+		Jump: b.Pressed(InputJump, w.Keys, pad),
+		Grab: b.Pressed(InputGrab, w.Keys, pad),
+	}
+}
+
+func (p *Player) Update(w *World, dt float64) {
+	p.Object.Update(w.Space, dt, w.Width, w.Height)
+
+	if p.grounded && !p.wasGrounded && p.fallSpeed > landingSpeed {
+		w.emit(Event{Kind: EventLand, Pos: p.Position().Add(cp.Vector{0, p.Circle.Radius()}), Player: p})
+	}
+	p.wasGrounded = p.grounded
+	p.fallSpeed = p.Velocity().Y
+
+	// Grab a rope within reach, or let go of the one held. Jumping lets go
+	// too, keeping the swing's speed.
+	if p.grabHeld && !p.lastGrabState {
+		if p.swing != nil {
+			p.letGo(w)
 		} else {
-			p.inputX = 0
+			p.grab(w)
 		}
-		p.jumpHeld = g.Keys[glfw.KeySpace]
+	}
+	p.lastGrabState = p.grabHeld
+	if p.swing != nil && p.jumpHeld && !p.lastJumpState {
+		p.letGo(w)
 	}
 
 	// If the jump key was just pressed this frame, jump!
@@ -99,18 +163,21 @@ func (p *Player) Update(g *Game, dt float64) {
 		p.SetVelocityVector(p.Velocity().Add(cp.Vector{0, jumpV}))
 
 		p.remainingBoost = JumpBoostHeight / jumpV
+		p.Stats.Jumps++
+		w.emit(Event{Kind: EventJump, Pos: p.Position(), Player: p})
 	}
 	p.remainingBoost -= dt
 	p.lastJumpState = p.jumpHeld
 }
 
 func (p *Player) Draw(g *Game, alpha float64) {
-	g.SpriteRenderer.DrawSprite(
-		g.Texture("face"),
-		p.SmoothPos(alpha),
-		p.Size().Mul(1.1), // increase 10% to better fit hitbox
-		p.SmoothAngle(alpha),
-		p.Color)
+	// increase 10% to better fit hitbox
+	texture, size, rotate := p.look.frame(p.Size().Mul(1.1), p.SmoothAngle(alpha))
+	color := p.Color
+	if p.out {
+		color = eng.Grey
+	}
+	g.drawSprite(texture, p.SmoothPos(alpha), size, rotate, color)
 }
 
 const (
@@ -122,13 +189,18 @@ const (
 	PlayerAirAccelTime = 0.25
 	PlayerAirAccel     = PlayerVelocity / PlayerAirAccelTime
 
+	PlayerSwingAccel = 800.0
+
 	JumpHeight      = 250.0
 	JumpBoostHeight = 955.0
 	FallVelocity    = 900.0
 	Gravity         = 2000.0
+
+	// falling faster than this kicks up dust on landing
+	landingSpeed = 400.0
 )
 
-func playerUpdateVelocity(g *Game, p *Player) func(*cp.Body, cp.Vector, float64, float64) {
+func playerUpdateVelocity(p *Player) func(*cp.Body, cp.Vector, float64, float64) {
 	return func(body *cp.Body, gravity cp.Vector, damping, dt float64) {
 		// Use pre-polled input (set once per frame in Player.Update).
 		x := p.inputX
@@ -138,10 +210,15 @@ func playerUpdateVelocity(g *Game, p *Player) func(*cp.Body, cp.Vector, float64,
 		// Only count normals pointing sufficiently upward (n.Y > 0.7) to avoid
 		// wall-sticking letting the player jump off steep walls.
 		groundNormal := cp.Vector{}
+		var ground *Wall
+		var groundBody *cp.Body
 		body.EachArbiter(func(arb *cp.Arbiter) {
 			n := arb.Normal()
 			if n.Y > 0.7 && n.Y > groundNormal.Y {
 				groundNormal = n
+				_, other := arb.Shapes()
+				ground, _ = other.UserData.(*Wall)
+				groundBody = other.Body()
 			}
 		})
 
@@ -158,14 +235,32 @@ func playerUpdateVelocity(g *Game, p *Player) func(*cp.Body, cp.Vector, float64,
 		}
 		body.UpdateVelocity(grav, damping, dt)
 
-		// Target horizontal speed for air/ground control
-		targetVx := PlayerVelocity * x
+		// Target horizontal speed for air/ground control, slowed by mud and
+		// carried along by conveyors and moving platforms
+		material := MaterialNormal.properties()
+		if ground != nil {
+			material = ground.Material.properties()
+		}
+		targetVx := PlayerVelocity * x * material.speed
+		if p.grounded && ground != nil {
+			targetVx += ground.surfaceVelocity().X
+		}
+		if p.grounded && groundBody != nil && groundBody.GetType() == cp.BODY_KINEMATIC {
+			feet := body.Position().Add(groundNormal.Mult(p.Circle.Radius()))
+			targetVx += groundBody.VelocityAtWorldPoint(feet).X
+		}
 
-		// Apply air control if not grounded
+		// Apply air control if not grounded, and slide about on ice. Swinging
+		// players pump the swing instead, and let it swing when they don't.
 		v := p.Velocity()
-		if !p.grounded {
+		switch {
+		case p.swing != nil:
+			p.SetVelocity(v.X+x*PlayerSwingAccel*dt, v.Y)
+		case !p.grounded:
 			p.SetVelocity(cp.LerpConst(v.X, targetVx, PlayerAirAccel*dt), v.Y)
-		} else {
+		case material.slide > 0:
+			p.SetVelocity(cp.LerpConst(v.X, targetVx, material.slide*dt), v.Y)
+		default:
 			p.SetVelocity(targetVx, v.Y)
 		}
 
@@ -0,0 +1,104 @@
+package fam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const profilesFile = "profiles.json"
+
+// Profile is who a player is: the name drawn over their face and their colour.
+type Profile struct {
+	Name  string
+	Color mgl32.Vec3
+}
+
+// Profiles are the profiles picked on the join screen, by controller, so
+// players come back as themselves next time. Gamepads are known by their
+// GUID and keyboard players by their key set.
+type Profiles map[string]Profile
+
+func LoadProfiles(filename string) (Profiles, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var profiles Profiles
+	if err = json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	if profiles == nil {
+		profiles = Profiles{}
+	}
+	return profiles, nil
+}
+
+func SaveProfiles(filename string, profiles Profiles) error {
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// playerNames are offered on the join screen, after the names players
+// have picked before.
+var playerNames = []string{"Kiwi", "Mango", "Plum", "Pip", "Bean", "Nugget", "Pickle", "Waffle", "Noodle", "Sprout"}
+
+// profileKey is what the profile of input is saved under, empty for inputs
+// that don't keep one, like bots.
+func (g *Game) profileKey(input InputSource) string {
+	switch input := input.(type) {
+	case JoystickInput:
+		joy := glfw.Joystick(input)
+		guid := joy.GetGUID()
+		// a GUID names the model, so number pads of the same model in port order
+		n := 1
+		for other := glfw.Joystick1; other < joy; other++ {
+			if other.Present() && other.GetGUID() == guid {
+				n++
+			}
+		}
+		if n > 1 {
+			return fmt.Sprintf("%v #%d", guid, n)
+		}
+		return guid
+	case KeySetInput:
+		if int(input) < len(g.KeySets) {
+			return "Keyboard " + g.KeySets[input].Name
+		}
+	}
+	return ""
+}
+
+// applyProfiles gives players who joined without the join screen, like a
+// gamepad plugged in mid-game, the name and colour they picked last time.
+func (g *Game) applyProfiles() {
+	if g.Replaying() {
+		return
+	}
+	for _, p := range g.Players {
+		if p.Name != "" {
+			continue
+		}
+		if profile, ok := g.profiles[g.profileKey(p.Input)]; ok && profile.Name != "" {
+			p.Name = profile.Name
+			p.Color = profile.Color
+		}
+	}
+}
+
+// colorIndex is where color is in the palette, or -1.
+func colorIndex(color mgl32.Vec3) int {
+	for i, c := range eng.Colors {
+		if c == color {
+			return i
+		}
+	}
+	return -1
+}
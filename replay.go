@@ -0,0 +1,418 @@
+package fam
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+
+	"github.com/jakecoffman/cp/v2"
+)
+
+// A replay file is a gzipped stream: a header holding the seed the world was
+// reset with, the level as JSON, the game mode and round length and the
+// players present, then one Frame per tick. Floats are 8 bytes little endian,
+// and a frame only holds the mouse when it moved.
+const replayMagic = "FAMREPLAY\x08"
+
+// Frame is everything that drove the simulation during one tick. Replaying
+// the frames of a session into a world reset with the same seed reproduces it.
+type Frame struct {
+	Mouse   cp.Vector
+	Modes   Modes
+	Actions []Action
+	Players []PlayerInput
+}
+
+// PlayerInput is what Player.Update polls from a controller each tick.
+type PlayerInput struct {
+	// X is recorded in steps of 1/inputSteps.
+	X    float64
+	Jump bool
+	// Grab grabs ropes and chains, and lets go of them again.
+	Grab bool
+}
+
+// the bits a PlayerInput's buttons are recorded in
+const (
+	inputJumpBit = 1 << iota
+	inputGrabBit
+)
+
+// inputSteps is how finely PlayerInput.X is recorded: in an int8, from
+// -inputSteps to inputSteps.
+const inputSteps = 127
+
+// quantize rounds X to what a recording of it keeps, so live play and its
+// replay see the same input.
+func (in PlayerInput) quantize() PlayerInput {
+	in.X = math.Round(math.Max(-1, math.Min(1, in.X))*inputSteps) / inputSteps
+	return in
+}
+
+// Modes are the pause menu toggles that change the simulation.
+type Modes byte
+
+const (
+	modeChaseBanana Modes = 1 << iota
+	modeRandomBomb
+)
+
+// frameMouseBit is set in the modes byte of a frame that holds the mouse.
+const frameMouseBit = 1 << 7
+
+type ActionKind byte
+
+const (
+	_ ActionKind = iota
+	ActionSpawnBanana
+	ActionSpawnBomb
+	ActionAddPlayer
+	ActionJoystickConnected
+	// ActionGrab is a left click: drag the object under Pos or start a wall.
+	ActionGrab
+	ActionRelease
+	// ActionDeleteWall is a right click: delete the wall, bumper or spring
+	// pad under Pos.
+	ActionDeleteWall
+	ActionAddBot
+	ActionRemoveBot
+	ActionSpawnCrate
+	ActionSpawnBumper
+	ActionSpawnSpring
+	// ActionNewRound resets the world for the next round. It's recorded at
+	// the start of the round, so replays follow the world into it.
+	ActionNewRound
+)
+
+// Action is a discrete input that changes the world, applied at the start
+// of the tick after it happened.
+type Action struct {
+	Kind     ActionKind
+	Pos      cp.Vector
+	Joystick JoystickInput
+	// Bot is the behaviour of the bot ActionAddBot adds.
+	Bot BotBehaviour
+	// Material is what a wall ActionGrab starts drawing is made of.
+	Material WallMaterial
+}
+
+// Recorder streams frames to a replay file.
+type Recorder struct {
+	file *os.File
+	zw   *gzip.Writer
+	w    *bufio.Writer
+	err  error
+
+	// mouse is where the last frame left the mouse
+	mouse cp.Vector
+}
+
+// NewRecorder creates a replay file for a world just reset with seed.
+func NewRecorder(filename string, seed int64, level *Level, mode string, roundLength float64, players []*Player) (*Recorder, error) {
+	levelJSON, err := json.Marshal(level)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(file)
+	r := &Recorder{
+		file: file,
+		zw:   zw,
+		w:    bufio.NewWriter(zw),
+	}
+	_, _ = r.w.WriteString(replayMagic)
+	r.varint(seed)
+	r.uvarint(uint64(len(levelJSON)))
+	if r.err == nil {
+		_, r.err = r.w.Write(levelJSON)
+	}
+	r.string(mode)
+	r.float(roundLength)
+	r.uvarint(uint64(len(players)))
+	for _, p := range players {
+		r.input(p.Input)
+		var joined byte
+		if p.Joined {
+			joined = 1
+		}
+		r.byte(joined)
+	}
+	return r, r.err
+}
+
+// Record appends a frame. Errors are sticky and reported by Close.
+func (r *Recorder) Record(f *Frame) {
+	modes := byte(f.Modes)
+	if f.Mouse != r.mouse {
+		modes |= frameMouseBit
+	}
+	r.byte(modes)
+	if f.Mouse != r.mouse {
+		r.float(f.Mouse.X)
+		r.float(f.Mouse.Y)
+		r.mouse = f.Mouse
+	}
+	r.uvarint(uint64(len(f.Actions)))
+	for _, a := range f.Actions {
+		r.byte(byte(a.Kind))
+		r.float(a.Pos.X)
+		r.float(a.Pos.Y)
+		r.varint(int64(a.Joystick))
+		r.byte(byte(a.Bot))
+		r.byte(byte(a.Material))
+	}
+	r.uvarint(uint64(len(f.Players)))
+	for _, p := range f.Players {
+		r.byte(byte(int8(math.Round(p.X * inputSteps))))
+		var buttons byte
+		if p.Jump {
+			buttons |= inputJumpBit
+		}
+		if p.Grab {
+			buttons |= inputGrabBit
+		}
+		r.byte(buttons)
+	}
+}
+
+// Close flushes the file and returns the first error hit while recording.
+func (r *Recorder) Close() error {
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.zw.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+func (r *Recorder) byte(b byte) {
+	if r.err == nil {
+		r.err = r.w.WriteByte(b)
+	}
+}
+
+func (r *Recorder) uvarint(v uint64) {
+	if r.err == nil {
+		_, r.err = r.w.Write(binary.AppendUvarint(nil, v))
+	}
+}
+
+func (r *Recorder) varint(v int64) {
+	if r.err == nil {
+		_, r.err = r.w.Write(binary.AppendVarint(nil, v))
+	}
+}
+
+func (r *Recorder) float(f float64) {
+	if r.err == nil {
+		_, r.err = r.w.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+	}
+}
+
+func (r *Recorder) string(s string) {
+	r.uvarint(uint64(len(s)))
+	if r.err == nil {
+		_, r.err = r.w.WriteString(s)
+	}
+}
+
+// Replay is a recorded session loaded into memory.
+type Replay struct {
+	Seed  int64
+	Level *Level
+	// Mode names the game mode, played for RoundLength seconds
+	Mode        string
+	RoundLength float64
+	Players     []ReplayPlayer
+	Frames      []Frame
+
+	tick int
+}
+
+// ReplayPlayer is a player present as the recording started.
+type ReplayPlayer struct {
+	Input InputSource
+	// Joined is the player's Joined, which decides whether resets keep them.
+	Joined bool
+}
+
+// LoadReplay reads a replay file written by a Recorder.
+func LoadReplay(filename string) (*Replay, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	d := &replayDecoder{r: bufio.NewReader(zr)}
+
+	magic := make([]byte, len(replayMagic))
+	if _, err = io.ReadFull(d.r, magic); err != nil || string(magic) != replayMagic {
+		return nil, errors.New("not a replay file")
+	}
+
+	replay := &Replay{Seed: d.varint(), Level: NewLevel()}
+	levelJSON := make([]byte, d.uvarint())
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, levelJSON)
+	}
+	if d.err == nil {
+		d.err = json.Unmarshal(levelJSON, replay.Level)
+	}
+	replay.Mode = d.string()
+	replay.RoundLength = d.float()
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		p := ReplayPlayer{Input: d.input()}
+		p.Joined = d.byte() != 0
+		replay.Players = append(replay.Players, p)
+	}
+	var mouse cp.Vector
+	for d.err == nil {
+		if _, err = d.r.Peek(1); err == io.EOF {
+			break
+		}
+		var f Frame
+		modes := d.byte()
+		if modes&frameMouseBit != 0 {
+			mouse.X = d.float()
+			mouse.Y = d.float()
+		}
+		f.Mouse = mouse
+		f.Modes = Modes(modes &^ frameMouseBit)
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			var a Action
+			a.Kind = ActionKind(d.byte())
+			a.Pos.X = d.float()
+			a.Pos.Y = d.float()
+			a.Joystick = JoystickInput(d.varint())
+			a.Bot = BotBehaviour(d.byte())
+			a.Material = WallMaterial(d.byte())
+			f.Actions = append(f.Actions, a)
+		}
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			var p PlayerInput
+			p.X = float64(int8(d.byte())) / inputSteps
+			buttons := d.byte()
+			p.Jump = buttons&inputJumpBit != 0
+			p.Grab = buttons&inputGrabBit != 0
+			f.Players = append(f.Players, p)
+		}
+		replay.Frames = append(replay.Frames, f)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return replay, nil
+}
+
+// Next returns the next frame, or nil once the replay is over.
+func (r *Replay) Next() *Frame {
+	if r.tick >= len(r.Frames) {
+		return nil
+	}
+	r.tick++
+	return &r.Frames[r.tick-1]
+}
+
+// kinds of InputSource in the replay header
+const (
+	inputJoystick byte = iota
+	inputKeySet
+	inputBot
+)
+
+// input writes what kind of controller a player has. Bots are replayed from
+// their recorded input, so only their behaviour is kept.
+func (r *Recorder) input(input InputSource) {
+	switch input := input.(type) {
+	case JoystickInput:
+		r.byte(inputJoystick)
+		r.varint(int64(input))
+	case KeySetInput:
+		r.byte(inputKeySet)
+		r.varint(int64(input))
+	case *Bot:
+		r.byte(inputBot)
+		r.varint(int64(input.Behaviour))
+	default:
+		r.byte(inputKeySet)
+		r.varint(0)
+	}
+}
+
+type replayDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *replayDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *replayDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *replayDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+func (d *replayDecoder) input() InputSource {
+	kind, v := d.byte(), d.varint()
+	switch kind {
+	case inputJoystick:
+		return JoystickInput(v)
+	case inputBot:
+		return NewBot(BotBehaviour(v), 0)
+	}
+	return KeySetInput(v)
+}
+
+func (d *replayDecoder) float() float64 {
+	if d.err != nil {
+		return 0
+	}
+	var b [8]byte
+	_, d.err = io.ReadFull(d.r, b[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+}
+
+func (d *replayDecoder) string() string {
+	b := make([]byte, d.uvarint())
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+	return string(b)
+}
@@ -0,0 +1,40 @@
+package fam
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jakecoffman/cp/v2"
+)
+
+func TestReplayFrames(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "frames.replay")
+	frames := []Frame{
+		{Players: []PlayerInput{{X: 1, Jump: true}}},
+		{Mouse: cp.Vector{12.5, -3}, Modes: modeRandomBomb, Players: []PlayerInput{PlayerInput{X: -.5}.quantize()}},
+		{Mouse: cp.Vector{12.5, -3}, Actions: []Action{{Kind: ActionSpawnBanana, Pos: cp.Vector{1.0 / 3, 2}}}},
+		{Mouse: cp.Vector{400, 300}, Modes: modeChaseBanana | modeRandomBomb, Players: []PlayerInput{PlayerInput{X: .3}.quantize(), {Grab: true}}},
+	}
+	r, err := NewRecorder(filename, 7, NewLevel(), "", 60, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range frames {
+		r.Record(&frames[i])
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := LoadReplay(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Seed != 7 || replay.RoundLength != 60 {
+		t.Errorf("header read back as seed %v and round length %v", replay.Seed, replay.RoundLength)
+	}
+	if !reflect.DeepEqual(replay.Frames, frames) {
+		t.Errorf("frames read back as\n%+v\nwant\n%+v", replay.Frames, frames)
+	}
+}
@@ -0,0 +1,168 @@
+package fam
+
+import (
+	"math"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const (
+	ropeLength = 200.0
+	// ropeLinkLength is how far apart the links of ropes and chains are
+	ropeLinkLength = 20.0
+	ropeLinkRadius = 4.0
+	ropeLinkMass   = 0.5
+	// ropeReach is how far past the edge of a player a link can be grabbed
+	ropeReach = 10.0
+)
+
+var (
+	ropeColor  = eng.FColor{.65, .5, .3, 1}
+	chainColor = eng.FColor{.6, .6, .65, 1}
+)
+
+// Rope hangs from a point on a wall, made of small bodies linked end to end.
+// Ropes link them with slide joints, so they go slack, and chains with pivot
+// joints, so each link stays rigid. Players pass through them, and can grab
+// one to swing on.
+type Rope struct {
+	Anchor cp.Vector
+	Length float64
+	Chain  bool
+
+	Links  []*cp.Body
+	shapes []*cp.Shape
+	joints []*cp.Constraint
+}
+
+// NewRope makes a rope hanging straight down from anchor. Like walls, it
+// isn't added to the space.
+func NewRope(w *World, anchor cp.Vector, length float64, chain bool) *Rope {
+	r := &Rope{Anchor: anchor, Length: length, Chain: chain}
+	n := int(math.Max(1, math.Round(length/ropeLinkLength)))
+	// links pass through players and each other
+	filter := cp.NewShapeFilter(uint(eng.GetObjectId()), ^PlayerMaskBit, ^PlayerMaskBit)
+
+	prev, prevEnd := w.Space.StaticBody, anchor
+	for i := 0; i < n; i++ {
+		end := anchor.Add(cp.Vector{0, float64(i+1) * ropeLinkLength})
+		var link *cp.Body
+		var shape *cp.Shape
+		if chain {
+			// a rigid bar from the last link's end to this one's, pinned at both
+			half := cp.Vector{0, ropeLinkLength / 2}
+			link = cp.NewBody(ropeLinkMass, cp.MomentForSegment(ropeLinkMass, half.Neg(), half, ropeLinkRadius))
+			link.SetPosition(prevEnd.Add(half))
+			shape = cp.NewSegment(link, half.Neg(), half, ropeLinkRadius)
+			r.joints = append(r.joints, cp.NewPivotJoint(prev, link, prevEnd))
+		} else {
+			// a bead that can't get further than a link from the last one
+			link = cp.NewBody(ropeLinkMass, cp.MomentForCircle(ropeLinkMass, 0, ropeLinkRadius, cp.Vector{}))
+			link.SetPosition(end)
+			shape = cp.NewCircle(link, ropeLinkRadius, cp.Vector{})
+			r.joints = append(r.joints, cp.NewSlideJoint(prev, link, prev.WorldToLocal(prevEnd), cp.Vector{}, 0, ropeLinkLength))
+		}
+		shape.SetFilter(filter)
+		shape.SetFriction(0.5)
+		shape.UserData = r
+		r.Links = append(r.Links, link)
+		r.shapes = append(r.shapes, shape)
+		prev, prevEnd = link, end
+	}
+	return r
+}
+
+// linkEnd is the world position of the far end of link i.
+func (r *Rope) linkEnd(i int) cp.Vector {
+	if r.Chain {
+		return r.Links[i].LocalToWorld(cp.Vector{0, ropeLinkLength / 2})
+	}
+	return r.Links[i].Position()
+}
+
+func (r *Rope) Draw(g *Game) {
+	prev := r.Anchor
+	for i := range r.Links {
+		end := r.linkEnd(i)
+		if r.Chain {
+			g.CPRenderer.DrawFatSegment(prev, end, ropeLinkRadius, eng.DefaultOutline, chainColor)
+		} else {
+			g.CPRenderer.DrawFatSegment(prev, end, ropeLinkRadius/2, ropeColor, ropeColor)
+		}
+		prev = end
+	}
+	g.CPRenderer.DrawDot(2*ropeLinkRadius, r.Anchor, eng.DefaultOutline)
+}
+
+// grab hangs p from the link of a rope closest to them, if one is in reach.
+func (p *Player) grab(w *World) {
+	var nearest *cp.Body
+	var rope *Rope
+	reach := p.Circle.Radius() + ropeReach
+	for _, r := range w.Ropes {
+		for _, link := range r.Links {
+			if d := link.Position().Distance(p.Position()); d <= reach {
+				nearest, rope, reach = link, r, d
+			}
+		}
+	}
+	if nearest == nil {
+		return
+	}
+	p.swing = cp.NewPivotJoint2(p.Body, nearest, cp.Vector{}, nearest.WorldToLocal(p.Position()))
+	p.swing.SetCollideBodies(false)
+	p.swingRope = rope
+	w.Space.AddConstraint(p.swing)
+}
+
+// letGo drops p from the rope they're hanging from.
+func (p *Player) letGo(w *World) {
+	if p.swing == nil {
+		return
+	}
+	if w.Space.ContainsConstraint(p.swing) {
+		w.Space.RemoveConstraint(p.swing)
+	}
+	p.swing, p.swingRope = nil, nil
+}
+
+// insertRope puts a rope into the world at index i of Ropes.
+func (w *World) insertRope(i int, r *Rope) {
+	w.Ropes = append(w.Ropes, nil)
+	copy(w.Ropes[i+1:], w.Ropes[i:])
+	w.Ropes[i] = r
+	for i, link := range r.Links {
+		w.Space.AddBody(link)
+		w.Space.AddShape(r.shapes[i])
+	}
+	for _, joint := range r.joints {
+		w.Space.AddConstraint(joint)
+	}
+}
+
+// removeRope takes a rope out of the world, dropping anyone swinging on it or
+// dragging it with the mouse, and returns the index it had in Ropes, or -1 if
+// it wasn't there.
+func (w *World) removeRope(r *Rope) int {
+	for _, p := range w.Players {
+		if p.swingRope == r {
+			p.letGo(w)
+		}
+	}
+	for i := range w.Ropes {
+		if w.Ropes[i] == r {
+			w.Ropes = append(w.Ropes[:i], w.Ropes[i+1:]...)
+			for _, joint := range r.joints {
+				w.Space.RemoveConstraint(joint)
+			}
+			for i, link := range r.Links {
+				w.releaseMouse(link)
+				w.Space.RemoveShape(r.shapes[i])
+				w.Space.RemoveBody(link)
+			}
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,118 @@
+package fam
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jakecoffman/fam/eng"
+)
+
+// how long the countdown before a round, the "Go!" after it and the summary
+// after a round show, in seconds
+const (
+	countdownLength = 3
+	goLength        = .7
+	summaryLength   = 8
+)
+
+// nextRound resets the world and counts down to the start of play.
+func (g *Game) nextRound() {
+	g.NewRound()
+	g.countdown = countdownLength
+	g.state = stateCountdown
+}
+
+// endRound stops play and shows who won and how everyone did, until the
+// next round starts by itself.
+func (g *Game) endRound(winners []*Player) {
+	g.winners = winners
+	g.summaryTime = summaryLength
+	g.summaryArmed = false
+	g.state = stateSummary
+}
+
+// updateRound moves the countdown and the summary along.
+func (g *Game) updateRound(dt float64) {
+	if g.countdown > -goLength {
+		g.countdown -= dt
+	}
+	switch g.state {
+	case stateCountdown:
+		if g.countdown <= 0 {
+			g.state = stateActive
+		}
+	case stateSummary:
+		g.summaryTime -= dt
+		if g.summaryTime <= 0 || g.pollSummary() {
+			g.nextRound()
+		}
+	}
+}
+
+// pollSummary reports whether someone pressed jump to skip the summary. Jump
+// has to be let go first, so whoever was jumping as the round ended doesn't
+// skip it straight away.
+func (g *Game) pollSummary() bool {
+	held := false
+	for _, p := range g.Players {
+		if _, ok := p.Input.(*Bot); ok || p.Input == nil {
+			continue
+		}
+		if p.Input.Poll(g.World).Jump {
+			held = true
+		}
+	}
+	// any key does too, for when only bots are playing
+	if len(g.Keys) > 0 {
+		held = true
+	}
+	if !held {
+		g.summaryArmed = true
+		return false
+	}
+	return g.summaryArmed
+}
+
+// renderCountdown shows the seconds to the start of the round, then "Go!".
+func (g *Game) renderCountdown(ww, wh int) {
+	text := "Go!"
+	if g.countdown > 0 {
+		text = fmt.Sprint(int(math.Ceil(g.countdown)))
+	} else if g.countdown <= -goLength {
+		return
+	}
+	g.TextRenderer.PrintAligned(text, float64(ww)/2, float64(wh)/2, 4, eng.AlignCenter)
+}
+
+// renderTimer shows the game mode and the time it has left, top right.
+func (g *Game) renderTimer(ww int) {
+	text := g.Mode().Name()
+	if _, free := g.Mode().(FreePlay); !free && g.RoundLength > 0 {
+		left := int(math.Ceil(math.Max(g.TimeLeft(), 0)))
+		text += fmt.Sprintf("  %d:%02d", left/60, left%60)
+	}
+	g.TextRenderer.PrintAligned(text, float64(ww)-10, 10+g.TextRenderer.LineHeight(1), 1, eng.AlignRight)
+}
+
+// renderWinners is the banner over the round summary, in the winner's colour
+// when there's only one.
+func (g *Game) renderWinners(x, y float64) {
+	var names []string
+	for _, p := range g.winners {
+		names = append(names, g.playerName(p))
+	}
+	var text string
+	switch len(names) {
+	case 0:
+		text = "Nobody wins"
+	case 1:
+		text = names[0] + " wins!"
+		c := g.winners[0].Color
+		g.TextRenderer.SetColor(c.X(), c.Y(), c.Z(), 1)
+	default:
+		text = strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1] + " win!"
+	}
+	g.TextRenderer.PrintAligned(text, x, y, 2, eng.AlignCenter)
+	g.TextRenderer.SetColor(1, 1, 1, 1)
+}
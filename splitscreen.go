@@ -0,0 +1,145 @@
+package fam
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+const (
+	// panes zoom in up to this much on their players
+	splitMaxZoom = 1.5
+	// players split up once they don't all fit in one view at splitMaxZoom,
+	// and merge again when they fit with splitHysteresis times less room
+	splitHysteresis = 1.2
+	// pixels between panes
+	splitGap = 2
+)
+
+// view is a camera and the part of the window it draws to, as fractions of
+// the window from the top left.
+type view struct {
+	Camera     *eng.Camera
+	X, Y, W, H float64
+}
+
+func (v view) contains(x, y float64) bool {
+	return x >= v.X && x < v.X+v.W && y >= v.Y && y < v.Y+v.H
+}
+
+// toWindow converts a world point to window coordinates.
+func (v view) toWindow(p cp.Vector, ww, wh int) (float64, float64) {
+	x, y := v.Camera.WorldToScreen(p, int(v.W*float64(ww)), int(v.H*float64(wh)))
+	return x + v.X*float64(ww), y + v.Y*float64(wh)
+}
+
+// toWorld converts window coordinates to the world.
+func (v view) toWorld(x, y float64, ww, wh int) cp.Vector {
+	return v.Camera.ScreenToWorld(x-v.X*float64(ww), y-v.Y*float64(wh), int(v.W*float64(ww)), int(v.H*float64(wh)))
+}
+
+// viewport points GL at the view's part of a framebuffer fbw by fbh big.
+func (v view) viewport(fbw, fbh int) {
+	x := int32(v.X * float64(fbw))
+	w := int32(v.W * float64(fbw))
+	h := int32(v.H * float64(fbh))
+	// GL counts rows from the bottom
+	y := int32(float64(fbh) - (v.Y+v.H)*float64(fbh))
+	if v.X > 0 {
+		x += splitGap / 2
+		w -= splitGap / 2
+	}
+	if v.X+v.W < 1 {
+		w -= splitGap / 2
+	}
+	if v.Y > 0 {
+		h -= splitGap / 2
+	}
+	if v.Y+v.H < 1 {
+		y += splitGap / 2
+		h -= splitGap / 2
+	}
+	gl.Viewport(x, y, w, h)
+}
+
+// splitLayouts are the panes for 2, 3 and 4 way splits.
+var splitLayouts = map[int][]view{
+	2: {{X: 0, Y: 0, W: .5, H: 1}, {X: .5, Y: 0, W: .5, H: 1}},
+	3: {{X: 0, Y: 0, W: .5, H: 1}, {X: .5, Y: 0, W: .5, H: .5}, {X: .5, Y: .5, W: .5, H: .5}},
+	4: {{X: 0, Y: 0, W: .5, H: .5}, {X: .5, Y: 0, W: .5, H: .5}, {X: 0, Y: .5, W: .5, H: .5}, {X: .5, Y: .5, W: .5, H: .5}},
+}
+
+// SplitScreen gives each team of players its own pane while they are far
+// apart. With n panes, player i is on team i % n.
+type SplitScreen struct {
+	// Panes is 2, 3 or 4, or 1 for no split screen.
+	Panes int
+
+	views []view
+	split bool
+}
+
+// Update follows each team with its pane, and decides whether the players are
+// close enough to share the main camera.
+func (s *SplitScreen) Update(g *Game, dt float64) {
+	if s.Panes < 2 || len(g.Players) < 2 || g.state == stateEdit {
+		s.split = false
+		return
+	}
+	// no empty panes when there are fewer players than panes
+	panes := min(s.Panes, len(g.Players), 4)
+	if len(s.views) != panes {
+		s.views = append([]view(nil), splitLayouts[panes]...)
+		for i := range s.views {
+			cam := eng.NewCamera(worldWidth*s.views[i].W, worldHeight*s.views[i].H)
+			cam.MaxZoom = splitMaxZoom
+			s.views[i].Camera = cam
+		}
+	}
+
+	if g.state == stateActive {
+		all := playersBB(g.Players)
+		room := splitMaxZoom
+		if s.split {
+			room *= splitHysteresis
+		}
+		fits := all.R-all.L+2*g.Camera.Margin <= g.Camera.ViewWidth/room &&
+			all.T-all.B+2*g.Camera.Margin <= g.Camera.ViewHeight/room
+		if s.split == fits {
+			s.split = !fits
+			if s.split {
+				// start the panes where the shared view is, so they ease apart
+				for _, v := range s.views {
+					v.Camera.Position, v.Camera.Zoom = g.Camera.Position, g.Camera.Zoom
+				}
+			}
+		}
+	}
+
+	for i, v := range s.views {
+		v.Camera.Bounds = g.Camera.Bounds
+		var team []*Player
+		for j := i; j < len(g.Players); j += panes {
+			team = append(team, g.Players[j])
+		}
+		if len(team) > 0 {
+			v.Camera.Follow(playersBB(team), dt)
+		}
+	}
+}
+
+// Views are the cameras to draw the world with this frame.
+func (s *SplitScreen) Views(main *eng.Camera) []view {
+	if !s.split {
+		return []view{{Camera: main, W: 1, H: 1}}
+	}
+	return s.views
+}
+
+func playersBB(players []*Player) cp.BB {
+	bb := players[0].BB()
+	for _, p := range players[1:] {
+		bb = bb.Merge(p.BB())
+	}
+	return bb
+}
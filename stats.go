@@ -0,0 +1,115 @@
+package fam
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jakecoffman/fam/eng"
+)
+
+// fruitKinds are the fruit NewBanana hands out, in the order they're listed.
+var fruitKinds = []string{"banana", "strawberry", "blueberry"}
+
+// Stats are what a player did this round. They're counted in the
+// simulation, so replays count the same.
+type Stats struct {
+	// Fruit is how many of each kind of fruit the player ate.
+	Fruit map[string]int
+	// Bombed is how many times a bomb went off on the player.
+	Bombed int
+	Jumps  int
+	// MaxSize is the biggest radius the player grew to.
+	MaxSize float64
+}
+
+func newStats(radius float64) Stats {
+	return Stats{Fruit: map[string]int{}, MaxSize: radius}
+}
+
+// Eaten is how many fruit the player ate, of any kind.
+func (s *Stats) Eaten() int {
+	var n int
+	for _, count := range s.Fruit {
+		n += count
+	}
+	return n
+}
+
+func (s *Stats) grow(radius float64) {
+	if radius > s.MaxSize {
+		s.MaxSize = radius
+	}
+}
+
+// Growth is MaxSize compared to the size players start at.
+func (s *Stats) Growth() float64 {
+	return s.MaxSize / playerRadius
+}
+
+// playerName is what the HUD and summary call p.
+func (g *Game) playerName(p *Player) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	if label := p.Input.Label(g.World); label != "" {
+		return label
+	}
+	for i, other := range g.Players {
+		if other == p {
+			return fmt.Sprintf("Player %d", i+1)
+		}
+	}
+	return "Player"
+}
+
+// renderHUD lists each player's stats along the top of the window, in their
+// colour.
+func (g *Game) renderHUD() {
+	const x, scale = 10, 0.8
+	y := 10 + g.TextRenderer.LineHeight(scale)
+	for _, p := range g.Players {
+		g.TextRenderer.SetColor(p.Color.X(), p.Color.Y(), p.Color.Z(), 1)
+		line := fmt.Sprintf("%v  %d fruit  %d bombed  %d jumps  %.1fx", g.playerName(p), p.Stats.Eaten(), p.Stats.Bombed, p.Stats.Jumps, p.Stats.Growth())
+		g.TextRenderer.Print(line, x, y, scale)
+		y += g.TextRenderer.LineHeight(scale)
+	}
+	g.TextRenderer.SetColor(1, 1, 1, 1)
+}
+
+// renderSummary shows the stats of the round that just ended, most fruit
+// first.
+func (g *Game) renderSummary(ww, wh int) {
+	players := append([]*Player{}, g.Players...)
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Stats.Eaten() > players[j].Stats.Eaten()
+	})
+
+	lineHeight := g.TextRenderer.LineHeight(1)
+	rows := len(players) + 6
+	x, y := float64(ww)/2, (float64(wh)-float64(rows)*lineHeight)/2+lineHeight
+
+	g.renderWinners(x, y)
+	y += 3 * lineHeight
+	for _, p := range players {
+		var fruit []string
+		for _, kind := range fruitKinds {
+			if n := p.Stats.Fruit[kind]; n > 0 {
+				fruit = append(fruit, fmt.Sprintf("%d %v", n, kind))
+			}
+		}
+		eaten := fmt.Sprintf("%d fruit", p.Stats.Eaten())
+		if len(fruit) > 0 {
+			eaten += " (" + strings.Join(fruit, ", ") + ")"
+		}
+		g.TextRenderer.SetColor(p.Color.X(), p.Color.Y(), p.Color.Z(), 1)
+		line := fmt.Sprintf("%v: %v, bombed %d times, %d jumps, grew to %.1fx", g.playerName(p), eaten, p.Stats.Bombed, p.Stats.Jumps, p.Stats.Growth())
+		g.TextRenderer.PrintAligned(line, x, y, 1, eng.AlignCenter)
+		y += lineHeight
+	}
+	g.TextRenderer.SetColor(1, 1, 1, 1)
+	y += lineHeight
+	next := fmt.Sprintf("Next round in %d, or press jump", int(math.Ceil(g.summaryTime)))
+	g.TextRenderer.PrintAligned(next, x, y, 1, eng.AlignCenter)
+}
@@ -1,28 +1,164 @@
 package fam
 
 import (
+	"fmt"
+
 	"github.com/jakecoffman/cp/v2"
 	"github.com/jakecoffman/fam/eng"
 )
 
 type Wall struct {
 	*cp.Segment
+
+	Material WallMaterial
 }
 
 const (
-	wallWidth    = 10
-	wallFriction = 100
+	wallWidth      = 10
+	wallFriction   = 100
+	wallElasticity = 1
 )
 
-func NewWall(g *Game, a, b cp.Vector) *Wall {
-	seg := cp.NewSegment(g.Space.StaticBody, a, b, wallWidth)
-	seg.SetElasticity(1)
-	seg.SetFriction(wallFriction)
+func NewWall(w *World, a, b cp.Vector) *Wall {
+	return newWall(w.Space.StaticBody, a, b)
+}
+
+// newWall makes a wall from a to b on body, in the body's coordinates.
+func newWall(body *cp.Body, a, b cp.Vector) *Wall {
+	seg := cp.NewSegment(body, a, b, wallWidth)
 	seg.SetCollisionType(collisionWall)
 	// don't add to space because we might be in a callback
-	return &Wall{
-		seg.Class.(*cp.Segment),
+	wall := &Wall{
+		Segment: seg.Class.(*cp.Segment),
+	}
+	seg.UserData = wall
+	wall.SetMaterial(MaterialNormal)
+	return wall
+}
+
+// WallMaterial is what a wall is made of, which changes how things move on it.
+type WallMaterial int
+
+const (
+	MaterialNormal WallMaterial = iota
+	// MaterialTrampoline throws whatever lands on it back up in the air.
+	MaterialTrampoline
+	// MaterialIce is slippery: players take a while to get going and to stop.
+	MaterialIce
+	// MaterialSticky is mud, slowing down everything on it.
+	MaterialSticky
+	// MaterialConveyor carries everything on it from A towards B.
+	MaterialConveyor
+)
+
+// WallMaterials lists every material in the order the pickers show them.
+var WallMaterials = []WallMaterial{MaterialNormal, MaterialTrampoline, MaterialIce, MaterialSticky, MaterialConveyor}
+
+type wallMaterial struct {
+	name                 string
+	friction, elasticity float64
+	// speed scales how fast players walk on it. slide is how quickly they
+	// speed up and slow down, zero for straight away.
+	speed, slide  float64
+	outline, fill eng.FColor
+}
+
+var wallMaterials = []wallMaterial{
+	MaterialNormal:     {name: "normal", friction: wallFriction, elasticity: wallElasticity, speed: 1, outline: eng.DefaultOutline, fill: eng.DefaultFill},
+	MaterialTrampoline: {name: "trampoline", friction: wallFriction, elasticity: wallElasticity, speed: 1, outline: eng.FColor{.5, 0, .25, 1}, fill: eng.FColor{1, .4, .7, 1}},
+	MaterialIce:        {name: "ice", friction: .02, elasticity: wallElasticity, speed: 1, slide: 600, outline: eng.FColor{.2, .5, .7, 1}, fill: eng.FColor{.75, .95, 1, 1}},
+	MaterialSticky:     {name: "sticky", friction: wallFriction, elasticity: 0, speed: .35, outline: eng.FColor{.2, .12, .05, 1}, fill: eng.FColor{.45, .3, .15, 1}},
+	MaterialConveyor:   {name: "conveyor", friction: wallFriction, elasticity: wallElasticity, speed: 1, outline: eng.FColor{.1, .1, .1, 1}, fill: eng.FColor{.35, .35, .4, 1}},
+}
+
+const (
+	// trampolineSpeed is how fast trampolines throw things up, a bit faster
+	// than a jump
+	trampolineSpeed = 1600.0
+	conveyorSpeed   = 200.0
+	// stickyDrag is how much of their speed things sliding through mud keep
+	// each step
+	stickyDrag = 0.9
+)
+
+var conveyorArrowColor = eng.FColor{1, .8, .2, 1}
+
+func (m WallMaterial) String() string {
+	if m < 0 || int(m) >= len(wallMaterials) {
+		return fmt.Sprintf("material %d", int(m))
+	}
+	return wallMaterials[m].name
+}
+
+func (m WallMaterial) MarshalText() ([]byte, error) {
+	if m < 0 || int(m) >= len(wallMaterials) {
+		return nil, fmt.Errorf("unknown wall material %d", int(m))
 	}
+	return []byte(wallMaterials[m].name), nil
+}
+
+func (m *WallMaterial) UnmarshalText(text []byte) error {
+	for i, material := range wallMaterials {
+		if material.name == string(text) {
+			*m = WallMaterial(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown wall material %q", text)
+}
+
+func (m WallMaterial) properties() wallMaterial {
+	if m < 0 || int(m) >= len(wallMaterials) {
+		return wallMaterials[MaterialNormal]
+	}
+	return wallMaterials[m]
+}
+
+// SetMaterial makes the wall out of m, with its friction and bounce.
+func (w *Wall) SetMaterial(m WallMaterial) {
+	w.Material = m
+	props := m.properties()
+	w.SetFriction(props.friction)
+	w.SetElasticity(props.elasticity)
+	w.SetSurfaceV(w.surfaceVelocity())
+}
+
+// SetEndpoints moves the wall, turning a conveyor to run along it.
+func (w *Wall) SetEndpoints(a, b cp.Vector) {
+	w.Segment.SetEndpoints(a, b)
+	w.SetSurfaceV(w.surfaceVelocity())
+}
+
+// Ends are the wall's endpoints in the world, which are only different from
+// A and B for walls on moving platforms.
+func (w *Wall) Ends() (cp.Vector, cp.Vector) {
+	body := w.Body()
+	return body.LocalToWorld(w.A()), body.LocalToWorld(w.B())
+}
+
+// surfaceVelocity is how fast the wall's surface moves, which is only
+// anything for conveyors.
+func (w *Wall) surfaceVelocity() cp.Vector {
+	a, b := w.Ends()
+	if w.Material != MaterialConveyor || a == b {
+		return cp.Vector{}
+	}
+	return b.Sub(a).Normalize().Mult(conveyorSpeed)
+}
+
+// WallBegin bounces things off trampolines as they land on them.
+func WallBegin(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
+	a, b := arb.Shapes()
+	wall, ok := a.UserData.(*Wall)
+	if !ok || wall.Material != MaterialTrampoline {
+		return true
+	}
+	// only from above, the same as landing on any platform
+	if arb.Normal().Y < -0.5 && b.Body().GetType() == cp.BODY_DYNAMIC {
+		v := b.Body().Velocity()
+		b.Body().SetVelocity(v.X, -trampolineSpeed)
+	}
+	return true
 }
 
 func WallPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
@@ -31,9 +167,76 @@ func WallPreSolve(arb *cp.Arbiter, space *cp.Space, data interface{}) bool {
 		return arb.Ignore()
 	}
 
+	a, b := arb.Shapes()
+	if wall, ok := a.UserData.(*Wall); ok && wall.Material == MaterialSticky {
+		// players walk slowly in mud on their own
+		if _, player := b.UserData.(*Player); !player && b.Body().GetType() == cp.BODY_DYNAMIC {
+			b.Body().SetVelocityVector(b.Body().Velocity().Mult(stickyDrag))
+		}
+	}
+
 	return true
 }
 
 func (w *Wall) Draw(g *Game, alpha float64) {
-	g.CPRenderer.DrawFatSegment(w.A(), w.B(), w.Radius(), eng.DefaultOutline, eng.DefaultFill)
+	props := w.Material.properties()
+	a, b := w.Ends()
+	g.CPRenderer.DrawFatSegment(a, b, w.Radius(), props.outline, props.fill)
+	if w.Material == MaterialConveyor {
+		w.drawArrows(g)
+	}
+}
+
+// drawArrows marks which way a conveyor runs with chevrons along it.
+func (w *Wall) drawArrows(g *Game) {
+	const spacing, size = 40.0, 5.0
+	a, b := w.Ends()
+	length := b.Distance(a)
+	if length == 0 {
+		return
+	}
+	dir := b.Sub(a).Mult(1 / length)
+	side := dir.Perp().Mult(size)
+	for d := spacing / 2; d < length; d += spacing {
+		tip := a.Add(dir.Mult(d + size/2))
+		back := tip.Sub(dir.Mult(size))
+		g.CPRenderer.DrawSegment(back.Add(side), tip, conveyorArrowColor)
+		g.CPRenderer.DrawSegment(back.Sub(side), tip, conveyorArrowColor)
+	}
+}
+
+// insertWall puts a wall into the world at index i of Walls.
+func (w *World) insertWall(i int, wall *Wall) {
+	w.Walls = append(w.Walls, nil)
+	copy(w.Walls[i+1:], w.Walls[i:])
+	w.Walls[i] = wall
+	w.Space.AddShape(wall.Shape)
+}
+
+// removeWall takes a wall out of the world and returns the index it had in
+// Walls, or -1 if it wasn't there.
+func (w *World) removeWall(wall *Wall) int {
+	for i := range w.Walls {
+		if w.Walls[i] == wall {
+			w.Walls = append(w.Walls[:i], w.Walls[i+1:]...)
+			if w.Space.ContainsShape(wall.Shape) {
+				w.Space.RemoveShape(wall.Shape)
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// moveWall changes a wall's endpoints. Static shapes aren't reindexed by the
+// space on their own, so the wall is taken out and put back around the move.
+func (w *World) moveWall(wall *Wall, a, b cp.Vector) {
+	inSpace := w.Space.ContainsShape(wall.Shape)
+	if inSpace {
+		w.Space.RemoveShape(wall.Shape)
+	}
+	wall.SetEndpoints(a, b)
+	if inSpace {
+		w.Space.AddShape(wall.Shape)
+	}
 }
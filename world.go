@@ -0,0 +1,697 @@
+package fam
+
+import (
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+// World is the simulation state of a game. It holds no window, shader or
+// texture so it can be stepped headless, e.g. with eng.RunHeadless.
+type World struct {
+	// Rand is the source of every random choice the simulation makes, so two
+	// worlds with the same seed and the same inputs stay in lockstep.
+	Rand *rand.Rand
+	seed int64
+
+	level *Level
+
+	// Keys holds the keyboard keys currently held down, read by keyboard players.
+	Keys map[Key]bool
+	// Controls are the keys, buttons and axes players control with.
+	Controls
+	// Gamepads maps each controller model to the standard gamepad layout.
+	Gamepads *GamepadDB
+
+	Space *cp.Space
+
+	// Width and Height are the size of the level. Objects leaving one side
+	// come back on the other.
+	Width, Height float64
+
+	Players []*Player
+	Bananas []*Banana
+	Bombs   []*Bomb
+	Walls   []*Wall
+	// Platforms are the walls that move.
+	Platforms []*MovingPlatform
+	Blocks    []*Block
+	Ropes     []*Rope
+	Bumpers   []*Bumper
+	Springs   []*SpringPad
+	Crates    []*Crate
+
+	// Events are what happened during the last tick.
+	Events []Event
+
+	chaseBananaMode bool
+	randomBombMode  bool
+
+	// mode is the rules of the round, which has been going for roundTime
+	// out of RoundLength seconds
+	mode        GameMode
+	RoundLength float64
+	roundTime   float64
+
+	// Mouse is the cursor in world space, kept current by the window.
+	Mouse cp.Vector
+
+	// mouse stuff, driven by the frame applied each tick
+	mouse      cp.Vector
+	mouseBody  *cp.Body
+	mouseJoint *cp.Constraint
+
+	leftDown *cp.Vector
+
+	drawingWallShape *Wall
+
+	// history of wall changes, for the editor's undo and redo
+	history editHistory
+
+	// actions queued by Do since the last tick
+	actions []Action
+
+	recorder *Recorder
+	replay   *Replay
+}
+
+// NewWorld creates a world with the initial level loaded and no players.
+// The seed drives all of the world's randomness.
+func NewWorld(seed int64) *World {
+	w := &World{
+		Rand:      rand.New(rand.NewSource(seed)),
+		seed:      seed,
+		Keys:      map[Key]bool{},
+		Controls:  DefaultControls(),
+		Gamepads:  NewGamepadDB(),
+		mouseBody: cp.NewKinematicBody(),
+
+		mode:        GameModes[0],
+		RoundLength: defaultRoundLength,
+	}
+	level, err := ReadLevel(initialLevel)
+	if err != nil {
+		panic(err)
+	}
+	w.level = level
+	w.reset()
+	return w
+}
+
+// Reseed resets the world and restarts its random source from seed.
+func (w *World) Reseed(seed int64) {
+	w.seed = seed
+	w.Rand.Seed(seed)
+	w.reset()
+}
+
+// Do queues an action to be applied at the start of the next tick. Live
+// input is dropped while a replay is playing.
+func (w *World) Do(a Action) {
+	if w.replay != nil {
+		return
+	}
+	w.actions = append(w.actions, a)
+}
+
+// Update advances the simulation by one fixed step.
+func (w *World) Update(dt float64) {
+	w.Events = w.Events[:0]
+
+	frame := w.nextFrame()
+	w.applyFrame(frame)
+	if w.recorder != nil {
+		w.recorder.Record(frame)
+	}
+
+	// update mouse body
+	newPoint := w.mouseBody.Position().Lerp(w.mouse, 0.25)
+	w.mouseBody.SetVelocityVector(newPoint.Sub(w.mouseBody.Position()).Mult(1.0 / eng.PhysicsDt))
+	w.mouseBody.SetPosition(newPoint)
+
+	if w.leftDown != nil {
+		w.drawingWallShape.SetEndpoints(*w.leftDown, w.mouse)
+	} else if w.drawingWallShape != nil {
+		w.Space.AddShape(w.drawingWallShape.Shape)
+		w.history.push(&addWallCommand{w.drawingWallShape})
+		w.drawingWallShape = nil
+	}
+
+	if w.chaseBananaMode && len(w.Bananas) == 0 {
+		x := w.Rand.Intn(int(w.Width))
+		y := w.Rand.Intn(int(w.Height))
+		banana := NewBanana(w, cp.Vector{float64(x), float64(y)}, 20)
+		banana.SetVelocity(float64(w.Rand.Intn(2000)-1000), float64(w.Rand.Intn(2000)-1000))
+		w.Bananas = append(w.Bananas, banana)
+	}
+	if w.randomBombMode && len(w.Bombs) == 0 {
+		x := w.Rand.Intn(int(w.Width))
+		y := w.Rand.Intn(int(w.Height))
+		bomb := NewBomb(cp.Vector{float64(x), float64(y)}, 20, w.Space)
+		bomb.SetVelocity(float64(w.Rand.Intn(2000)-1000), float64(w.Rand.Intn(2000)-1000))
+		w.Bombs = append(w.Bombs, bomb)
+	}
+
+	for i := range w.Bombs {
+		w.Bombs[i].Update(w, dt)
+	}
+	// Filter out gone bombs without discarding live ones.
+	out := w.Bombs[:0]
+	for _, b := range w.Bombs {
+		if b.state != bombStateGone {
+			out = append(out, b)
+		}
+	}
+	w.Bombs = out
+	for i := range w.Bananas {
+		w.Bananas[i].Update(w, dt)
+	}
+	for i := range w.Crates {
+		w.Crates[i].Update(w, dt)
+	}
+	for i := range w.Players {
+		w.Players[i].Update(w, dt)
+	}
+	for _, p := range w.Platforms {
+		p.Update(dt)
+	}
+	for _, b := range w.Bumpers {
+		b.Update(dt)
+	}
+	for _, s := range w.Springs {
+		s.Update(w)
+	}
+	w.roundTime += dt
+	w.mode.Update(w, dt)
+
+	w.Space.Step(dt)
+}
+
+// nextFrame returns the input for this tick, taken from the replay being
+// watched or gathered from the live keyboard, mouse and joysticks.
+func (w *World) nextFrame() *Frame {
+	if w.replay != nil {
+		if frame := w.replay.Next(); frame != nil {
+			return frame
+		}
+		log.Println("Replay finished")
+		w.replay = nil
+	}
+
+	frame := &Frame{
+		Mouse:   w.Mouse,
+		Actions: w.actions,
+	}
+	w.actions = nil
+	if w.chaseBananaMode {
+		frame.Modes |= modeChaseBanana
+	}
+	if w.randomBombMode {
+		frame.Modes |= modeRandomBomb
+	}
+	return frame
+}
+
+// applyFrame feeds a frame into the world. Live frames have their player
+// input filled in here so the recording sees what the players did.
+func (w *World) applyFrame(frame *Frame) {
+	w.mouse = frame.Mouse
+	w.chaseBananaMode = frame.Modes&modeChaseBanana != 0
+	w.randomBombMode = frame.Modes&modeRandomBomb != 0
+
+	for _, a := range frame.Actions {
+		w.apply(a)
+	}
+
+	if w.replay == nil {
+		frame.Players = make([]PlayerInput, len(w.Players))
+		for i, p := range w.Players {
+			frame.Players[i] = p.Poll(w).quantize()
+		}
+	}
+	for i, p := range w.Players {
+		if i < len(frame.Players) {
+			p.inputX = frame.Players[i].X
+			p.jumpHeld = frame.Players[i].Jump
+			p.grabHeld = frame.Players[i].Grab
+		} else {
+			p.inputX, p.jumpHeld, p.grabHeld = 0, false, false
+		}
+	}
+}
+
+func (w *World) apply(a Action) {
+	// give the mouse click a little radius to make it easier to click small shapes.
+	const clickRadius = 5
+
+	switch a.Kind {
+	case ActionSpawnBanana:
+		w.Bananas = append(w.Bananas, NewBanana(w, a.Pos, 20))
+	case ActionSpawnBomb:
+		w.Bombs = append(w.Bombs, NewBomb(a.Pos, 20, w.Space))
+	case ActionSpawnCrate:
+		w.spawnCrate(a.Pos)
+	case ActionSpawnBumper:
+		w.addBumper(NewBumper(w, a.Pos))
+	case ActionSpawnSpring:
+		w.addSpringPad(NewSpringPad(w, a.Pos))
+	case ActionNewRound:
+		// live worlds already reset when the round started, see NewRound
+		if w.replay != nil {
+			w.resetRound()
+		}
+	case ActionAddPlayer:
+		w.AddPlayer()
+	case ActionAddBot:
+		w.AddBot(a.Bot)
+	case ActionRemoveBot:
+		w.RemoveBot()
+	case ActionJoystickConnected:
+		for _, p := range w.Players {
+			if p.Input == JoystickInput(a.Joystick) {
+				log.Println("Joystick reconnected", a.Joystick)
+				return
+			}
+		}
+		log.Println("Joystick connected", a.Joystick)
+		p := NewPlayer(w.spawnPos(len(w.Players)), playerRadius, w)
+		p.Color = eng.NextColor()
+		p.Input = JoystickInput(a.Joystick)
+		w.Players = append(w.Players, p)
+	case ActionGrab:
+		info := w.Space.PointQueryNearest(a.Pos, clickRadius, NotGrabbableFilter)
+
+		if info.Shape != nil && info.Shape.Body().Mass() < cp.INFINITY {
+			var nearest cp.Vector
+			if info.Distance > 0 {
+				nearest = info.Point
+			} else {
+				nearest = a.Pos
+			}
+
+			body := info.Shape.Body()
+			w.mouseJoint = cp.NewPivotJoint2(w.mouseBody, body, cp.Vector{}, body.WorldToLocal(nearest))
+			w.mouseJoint.SetMaxForce(50000)
+			w.mouseJoint.SetErrorBias(math.Pow(1.0-0.15, 1.0/eng.PhysicsDt))
+			w.Space.AddConstraint(w.mouseJoint)
+		} else {
+			leftDown := a.Pos.Clone()
+			w.leftDown = &leftDown
+			wall := NewWall(w, *w.leftDown, a.Pos)
+			wall.SetMaterial(a.Material)
+			w.drawingWallShape = wall
+			w.Walls = append(w.Walls, w.drawingWallShape)
+		}
+	case ActionRelease:
+		if w.mouseJoint != nil {
+			w.Space.RemoveConstraint(w.mouseJoint)
+			w.mouseJoint = nil
+			return
+		}
+		w.leftDown = nil
+	case ActionDeleteWall:
+		info := w.Space.PointQueryNearest(a.Pos, clickRadius, NotGrabbableFilter)
+
+		if info.Shape != nil {
+			switch thing := info.Shape.UserData.(type) {
+			case *Bumper:
+				w.removeBumper(thing)
+				return
+			case *Wall:
+				for _, spring := range w.Springs {
+					if spring.Plate == thing {
+						w.removeSpringPad(spring)
+						return
+					}
+				}
+			}
+			if segment, ok := info.Shape.Class.(*cp.Segment); ok {
+				for _, wall := range w.Walls {
+					if segment == wall.Segment {
+						w.history.run(w, &deleteWallsCommand{walls: []*Wall{wall}})
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// StartRecording resets the world with its current seed and records every
+// tick from then on to filename, through new rounds, until StopRecording or
+// the next reset.
+func (w *World) StartRecording(filename string) error {
+	w.Reseed(w.seed)
+	recorder, err := NewRecorder(filename, w.seed, w.level, w.mode.Name(), w.RoundLength, w.Players)
+	if err != nil {
+		return err
+	}
+	log.Println("Recording to", filename)
+	w.recorder = recorder
+	return nil
+}
+
+func (w *World) StopRecording() {
+	if w.recorder == nil {
+		return
+	}
+	if err := w.recorder.Close(); err != nil {
+		log.Println(err)
+	}
+	log.Println("Recording stopped")
+	w.recorder = nil
+}
+
+func (w *World) Recording() bool {
+	return w.recorder != nil
+}
+
+// StartReplay rebuilds the world the replay was recorded in and plays it back,
+// one frame per tick. Live input is ignored until the replay finishes.
+func (w *World) StartReplay(replay *Replay) {
+	w.level = replay.Level
+	w.mode.Teardown(w)
+	w.mode = gameMode(replay.Mode)
+	w.RoundLength = replay.RoundLength
+	w.Players = nil
+	for _, rp := range replay.Players {
+		p := NewPlayer(cp.Vector{}, playerRadius, w)
+		p.Color = eng.NextColor()
+		p.Input = rp.Input
+		p.Joined = rp.Joined
+		w.Players = append(w.Players, p)
+	}
+	w.Reseed(replay.Seed)
+	w.replay = replay
+}
+
+func (w *World) StopReplay() {
+	if w.replay != nil {
+		log.Println("Replay stopped")
+		w.replay = nil
+	}
+}
+
+func (w *World) Replaying() bool {
+	return w.replay != nil
+}
+
+// AddPlayer spawns a keyboard player at the next spawn point, with the first
+// key set no one is using. It returns nil when every key set is taken.
+func (w *World) AddPlayer() *Player {
+	set := w.freeKeySet()
+	if set < 0 {
+		log.Println("Every key set is taken")
+		return nil
+	}
+	p := NewPlayer(w.spawnPos(len(w.Players)), playerRadius, w)
+	p.Color = eng.NextColor()
+	p.Input = KeySetInput(set)
+	w.Players = append(w.Players, p)
+	return p
+}
+
+// AddBot spawns a computer controlled player.
+func (w *World) AddBot(behaviour BotBehaviour) *Player {
+	p := NewPlayer(w.spawnPos(len(w.Players)), playerRadius, w)
+	p.Color = eng.NextColor()
+	p.Input = NewBot(behaviour, int64(len(w.Players)))
+	w.Players = append(w.Players, p)
+	return p
+}
+
+// RemoveBot removes the most recently added bot, if there is one.
+func (w *World) RemoveBot() {
+	for i := len(w.Players) - 1; i >= 0; i-- {
+		p := w.Players[i]
+		if _, ok := p.Input.(*Bot); ok {
+			p.letGo(w)
+			w.releaseMouse(p.Body)
+			w.Space.RemoveShape(p.Shape)
+			w.Space.RemoveBody(p.Body)
+			w.Players = append(w.Players[:i], w.Players[i+1:]...)
+			return
+		}
+	}
+}
+
+// releaseMouse lets go of body if the mouse is dragging it, so it can be
+// taken out of the space.
+func (w *World) releaseMouse(body *cp.Body) {
+	if w.mouseJoint == nil {
+		return
+	}
+	dragged := false
+	body.EachConstraint(func(c *cp.Constraint) {
+		dragged = dragged || c == w.mouseJoint
+	})
+	if dragged {
+		w.Space.RemoveConstraint(w.mouseJoint)
+		w.mouseJoint = nil
+	}
+}
+
+func (w *World) freeKeySet() int {
+next:
+	for i := range w.KeySets {
+		for _, p := range w.Players {
+			if p.Input == KeySetInput(i) {
+				continue next
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// KeyInUse reports whether key moves or jumps for a keyboard player.
+func (w *World) KeyInUse(key Key) bool {
+	for _, p := range w.Players {
+		if set, ok := p.Input.(KeySetInput); ok && int(set) < len(w.KeySets) && w.KeySets[set].HasKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSize resizes the level.
+func (w *World) SetSize(width, height float64) {
+	w.level.Width, w.level.Height = width, height
+	w.Width, w.Height = width, height
+}
+
+// spawnPos returns where the i-th player spawns, jittered a little so players
+// sharing a spawn point don't stack perfectly.
+func (w *World) spawnPos(i int) cp.Vector {
+	spawn := cp.Vector{w.Width / 2, w.Height / 2}
+	if len(w.level.Spawns) > 0 {
+		spawn = w.level.Spawns[i%len(w.level.Spawns)]
+	}
+	return cp.Vector{spawn.X + w.Rand.Float64()*10, spawn.Y + w.Rand.Float64()*10}
+}
+
+// Level returns the level the world resets to.
+func (w *World) Level() *Level {
+	return w.level
+}
+
+// reset rebuilds the space from the current level. Any recording or replay
+// stops, since it can't follow the world through a reset.
+func (w *World) reset() {
+	w.StopRecording()
+	w.replay = nil
+	w.resetRound()
+}
+
+// NewRound resets the world for the next round of the same game. Unlike
+// reset, recordings and replays carry on through it.
+func (w *World) NewRound() {
+	if w.replay != nil {
+		// the replay starts the round where it was recorded
+		return
+	}
+	w.resetRound()
+	w.actions = append(w.actions, Action{Kind: ActionNewRound})
+}
+
+// resetRound rebuilds the space from the current level, leaving any
+// recording or replay be.
+func (w *World) resetRound() {
+	w.Space = cp.NewSpace()
+	w.Space.Iterations = 10
+	w.Space.SetGravity(w.level.Gravity)
+	w.Width, w.Height = w.level.Width, w.level.Height
+
+	bananaCollisionHandler := w.Space.NewCollisionHandler(collisionBanana, collisionPlayer)
+	bananaCollisionHandler.PreSolveFunc = BananaPreSolve
+	bananaCollisionHandler.UserData = w
+
+	bombCollisionHandler := w.Space.NewWildcardCollisionHandler(collisionBomb)
+	bombCollisionHandler.PreSolveFunc = BombPreSolve
+	bombCollisionHandler.UserData = w
+
+	wallCollisionHandler := w.Space.NewWildcardCollisionHandler(collisionWall)
+	wallCollisionHandler.BeginFunc = WallBegin
+	wallCollisionHandler.PreSolveFunc = WallPreSolve
+
+	bumperCollisionHandler := w.Space.NewWildcardCollisionHandler(collisionBumper)
+	bumperCollisionHandler.BeginFunc = BumperBegin
+	bumperCollisionHandler.UserData = w
+
+	w.mouseJoint = nil
+	w.leftDown = nil
+	w.drawingWallShape = nil
+	w.actions = nil
+	w.Events = nil
+	w.history.clear()
+
+	w.chaseBananaMode = w.level.ChaseBanana
+	w.randomBombMode = w.level.RandomBombs
+
+	w.Walls = []*Wall{}
+	for _, lw := range w.level.Walls {
+		wall := NewWall(w, lw.A, lw.B)
+		wall.SetMaterial(lw.Material)
+		wall.SetFriction(lw.Friction)
+		wall.SetElasticity(lw.Elasticity)
+		w.Space.AddShape(wall.Segment.Shape)
+		w.Walls = append(w.Walls, wall)
+	}
+	w.Platforms = []*MovingPlatform{}
+	for _, lp := range w.level.Platforms {
+		if len(lp.Path) == 0 {
+			continue
+		}
+		path := append([]cp.Vector{}, lp.Path...)
+		platform := newPlatform(lp.Length, lp.Angle, path, lp.Motion, lp.Speed)
+		platform.SetMaterial(lp.Material)
+		w.insertPlatform(len(w.Platforms), platform)
+	}
+	w.Blocks = []*Block{}
+	for _, bb := range w.level.Blocks {
+		w.insertBlock(len(w.Blocks), NewBlock(w, bb))
+	}
+	w.Ropes = []*Rope{}
+	for _, lr := range w.level.Ropes {
+		w.insertRope(len(w.Ropes), NewRope(w, lr.Anchor, lr.Length, lr.Chain))
+	}
+	w.Bumpers = []*Bumper{}
+	for _, pos := range w.level.Bumpers {
+		w.addBumper(NewBumper(w, pos))
+	}
+	w.Springs = []*SpringPad{}
+	for _, base := range w.level.Springs {
+		w.addSpringPad(NewSpringPad(w, base))
+	}
+
+	w.Bananas = []*Banana{}
+	for _, lb := range w.level.Bananas {
+		banana := NewBanana(w, lb.Pos, 20)
+		if lb.Fruit != "" {
+			banana.Fruit = lb.Fruit
+		}
+		w.Bananas = append(w.Bananas, banana)
+	}
+	w.Bombs = []*Bomb{}
+	for _, pos := range w.level.Bombs {
+		w.Bombs = append(w.Bombs, NewBomb(pos, 20, w.Space))
+	}
+	w.Crates = []*Crate{}
+	for _, lc := range w.level.Crates {
+		crate := NewCrate(w, lc.Pos, lc.Sides, lc.Size)
+		crate.SetAngle(lc.Angle)
+		w.Crates = append(w.Crates, crate)
+	}
+
+	var players []*Player
+	for _, p := range w.Players {
+		if _, ok := p.Input.(KeySetInput); ok && !p.Joined {
+			// remove players created with "enter" for when the kids make too many players
+			continue
+		}
+		p.Reset(w.spawnPos(len(players)), playerRadius, w)
+		players = append(players, p)
+	}
+	w.Players = players
+
+	w.roundTime = 0
+	w.mode.Setup(w)
+}
+
+// saveLevel writes the walls, platforms, blocks, ropes, bumpers, spring
+// pads, bananas, bombs and crates as they are now, along with the current
+// level's metadata, spawning and spawn points.
+func (w *World) saveLevel(filename string) {
+	level := *w.level
+
+	level.Walls = nil
+	for _, wall := range w.Walls {
+		level.Walls = append(level.Walls, LevelWall{
+			A:          wall.A(),
+			B:          wall.B(),
+			Friction:   wall.Friction(),
+			Elasticity: wall.Elasticity(),
+			Material:   wall.Material,
+		})
+	}
+	level.Platforms = nil
+	for _, p := range w.Platforms {
+		level.Platforms = append(level.Platforms, LevelPlatform{
+			Path:     append([]cp.Vector{}, p.Path...),
+			Length:   p.Length(),
+			Angle:    p.Angle,
+			Motion:   p.Motion,
+			Speed:    p.Speed,
+			Material: p.Material,
+		})
+	}
+	level.Blocks = nil
+	for _, block := range w.Blocks {
+		level.Blocks = append(level.Blocks, block.BB)
+	}
+	level.Ropes = nil
+	for _, rope := range w.Ropes {
+		level.Ropes = append(level.Ropes, LevelRope{Anchor: rope.Anchor, Length: rope.Length, Chain: rope.Chain})
+	}
+	level.Bumpers = nil
+	for _, bumper := range w.Bumpers {
+		level.Bumpers = append(level.Bumpers, bumper.Pos)
+	}
+	level.Springs = nil
+	for _, spring := range w.Springs {
+		level.Springs = append(level.Springs, spring.Base)
+	}
+	level.Bananas = nil
+	for _, banana := range w.Bananas {
+		level.Bananas = append(level.Bananas, LevelBanana{Pos: banana.Position(), Fruit: banana.Fruit})
+	}
+	level.Bombs = nil
+	for _, bomb := range w.Bombs {
+		level.Bombs = append(level.Bombs, bomb.Position())
+	}
+	level.Crates = nil
+	for _, crate := range w.Crates {
+		level.Crates = append(level.Crates, LevelCrate{Pos: crate.Position(), Angle: crate.Angle(), Sides: crate.Sides, Size: crate.Size})
+	}
+
+	if err := WriteLevel(filename, &level); err != nil {
+		log.Println(err)
+		return
+	}
+	*w.level = level
+}
+
+// loadLevel switches to the level in the file and resets the world into it.
+func (w *World) loadLevel(name string) error {
+	level, err := ReadLevel(name)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	w.level = level
+	w.reset()
+	return nil
+}
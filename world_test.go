@@ -0,0 +1,202 @@
+package fam
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jakecoffman/cp/v2"
+	"github.com/jakecoffman/fam/eng"
+)
+
+func TestWorldRunsHeadless(t *testing.T) {
+	w := NewWorld(1)
+	p := w.AddPlayer()
+	if p == nil {
+		t.Fatal("no key set for the first player")
+	}
+	start := p.Position()
+
+	w.Keys[KeyD] = true
+	eng.RunHeadless(w, 60)
+
+	pos := p.Position()
+	if math.IsNaN(pos.X) || math.IsNaN(pos.Y) {
+		t.Fatalf("player is at %v", pos)
+	}
+	if pos.X <= start.X {
+		t.Errorf("holding right moved the player from %v to %v", start, pos)
+	}
+}
+
+// runSeeded plays the same inputs into a world with the given seed and
+// returns where everything ended up.
+func runSeeded(seed int64) []float64 {
+	w := NewWorld(seed)
+	w.chaseBananaMode, w.randomBombMode = true, true
+	w.AddPlayer()
+	w.AddPlayer()
+	w.AddBot(BotChaser)
+
+	w.Keys[KeyD] = true
+	eng.RunHeadless(w, 600)
+	w.Keys[KeySpace] = true
+	w.Keys[KeyLeft] = true
+	eng.RunHeadless(w, 600)
+
+	var out []float64
+	for _, p := range w.Players {
+		out = append(out, p.Position().X, p.Position().Y, p.Circle.Radius())
+	}
+	for _, b := range w.Bananas {
+		out = append(out, b.Position().X, b.Position().Y)
+	}
+	for _, b := range w.Bombs {
+		out = append(out, b.Position().X, b.Position().Y)
+	}
+	return out
+}
+
+func TestWorldSameSeedSameResult(t *testing.T) {
+	a, b := runSeeded(1), runSeeded(1)
+	if len(a) != len(b) {
+		t.Fatalf("worlds ended with %d and %d values", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("worlds diverged at value %d: %v != %v", i, a[i], b[i])
+		}
+	}
+	if reflect.DeepEqual(a, runSeeded(2)) {
+		t.Error("a different seed played out the same")
+	}
+}
+
+func TestRemoveBotBeingDragged(t *testing.T) {
+	w := NewWorld(1)
+	bot := w.AddBot(BotWanderer)
+	eng.RunHeadless(w, 1)
+
+	w.Do(Action{Kind: ActionGrab, Pos: bot.Position()})
+	w.Do(Action{Kind: ActionRemoveBot})
+	eng.RunHeadless(w, 1)
+	if len(w.Players) != 0 {
+		t.Fatalf("%d players left", len(w.Players))
+	}
+	if w.mouseJoint != nil {
+		t.Error("the mouse is still dragging the removed bot")
+	}
+	w.Space.EachConstraint(func(c *cp.Constraint) {
+		t.Errorf("constraint %p left in the space", c)
+	})
+	eng.RunHeadless(w, 10)
+}
+
+func TestRemoveBotSwinging(t *testing.T) {
+	w := NewWorld(1)
+	bot := w.AddBot(BotWanderer)
+	rope := NewRope(w, bot.Position().Sub(cp.Vector{0, ropeLength}), ropeLength, false)
+	w.insertRope(0, rope)
+	bot.grab(w)
+	if bot.swing == nil {
+		t.Fatal("the bot couldn't reach the rope")
+	}
+
+	w.Do(Action{Kind: ActionRemoveBot})
+	eng.RunHeadless(w, 1)
+	count := 0
+	w.Space.EachConstraint(func(c *cp.Constraint) {
+		count++
+	})
+	if count != len(rope.joints) {
+		t.Errorf("%d constraints in the space, want the rope's %d", count, len(rope.joints))
+	}
+	eng.RunHeadless(w, 10)
+}
+
+func TestRemoveSpringPadBeingDragged(t *testing.T) {
+	w := NewWorld(1)
+	w.Do(Action{Kind: ActionSpawnSpring, Pos: cp.Vector{200, 200}})
+	eng.RunHeadless(w, 1)
+	plate := w.Springs[0].Plate.Body().Position()
+
+	w.Do(Action{Kind: ActionGrab, Pos: plate})
+	eng.RunHeadless(w, 1)
+	if w.mouseJoint == nil {
+		t.Fatal("couldn't grab the plate")
+	}
+	w.Do(Action{Kind: ActionDeleteWall, Pos: plate})
+	eng.RunHeadless(w, 1)
+	if len(w.Springs) != 0 {
+		t.Fatal("the spring pad wasn't removed")
+	}
+	if w.mouseJoint != nil {
+		t.Error("the mouse is still dragging the removed plate")
+	}
+	eng.RunHeadless(w, 10)
+}
+
+func TestReplayJoinedKeyboardPlayer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "joined.replay")
+	w := NewWorld(1)
+	p := w.AddPlayer()
+	p.Joined = true
+	if err := w.StartRecording(filename); err != nil {
+		t.Fatal(err)
+	}
+	w.Keys[KeyD] = true
+	eng.RunHeadless(w, 120)
+	w.Keys[KeyW] = true
+	eng.RunHeadless(w, 120)
+	w.StopRecording()
+	want := p.Position()
+
+	replay, err := LoadReplay(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := NewWorld(2)
+	watched.StartReplay(replay)
+	eng.RunHeadless(watched, 240)
+	if len(watched.Players) != 1 {
+		t.Fatalf("replay has %d players, want 1", len(watched.Players))
+	}
+	if got := watched.Players[0].Position(); got != want {
+		t.Errorf("replayed player ended at %v, recorded at %v", got, want)
+	}
+}
+
+func TestReplayAcrossRounds(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rounds.replay")
+	w := NewWorld(1)
+	p := w.AddPlayer()
+	p.Joined = true
+	if err := w.StartRecording(filename); err != nil {
+		t.Fatal(err)
+	}
+	w.Keys[KeyD] = true
+	eng.RunHeadless(w, 120)
+	w.NewRound()
+	w.Do(Action{Kind: ActionSpawnBanana, Pos: p.Position().Add(cp.Vector{60, -60})})
+	eng.RunHeadless(w, 120)
+	if !w.Recording() {
+		t.Fatal("the new round stopped the recording")
+	}
+	w.StopRecording()
+	want := p.Position()
+
+	replay, err := LoadReplay(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := NewWorld(2)
+	watched.StartReplay(replay)
+	eng.RunHeadless(watched, 240)
+	if got := watched.Players[0].Position(); got != want {
+		t.Errorf("replayed player ended at %v, recorded at %v", got, want)
+	}
+	if len(watched.Bananas) != len(w.Bananas) {
+		t.Errorf("replay has %d bananas, recording %d", len(watched.Bananas), len(w.Bananas))
+	}
+}